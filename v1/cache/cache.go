@@ -98,6 +98,27 @@ func SetJSON(key string, value interface{}, seconds int) error {
 	return errors.Wrap(client.Set(key, valueJSON, time.Duration(seconds)*time.Second).Err(), "redis set failed")
 }
 
+// SetNX sets value only if key does not exist
+// @key: string
+// @value: interface{}
+// @seconds: int
+// return bool (true if set), error
+func SetNX(key string, value interface{}, seconds int) (bool, error) {
+	if !IsCacheConnected() {
+		return false, fmt.Errorf("redis connect failed: %s", os.Getenv("REDIS_HOST"))
+	}
+
+	client := getRedisClient()
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return false, errors.Wrap(err, "marshal failed")
+	}
+
+	isSet, err := client.SetNX(key, valueJSON, time.Duration(seconds)*time.Second).Result()
+	return isSet, errors.Wrap(err, "redis setnx failed")
+}
+
 // IsCacheExists params
 // @key: string
 // return bool, error
@@ -0,0 +1,201 @@
+// Package cachetest provides an in-memory redis server for testing code using the cache package
+package cachetest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value    string
+	expireAt time.Time
+}
+
+// Server serves the PING, GET, SET, SETNX, EXISTS, DEL, EXPIRE, TTL and FLUSHALL commands
+type Server struct {
+	listener net.Listener
+
+	mu   sync.Mutex
+	data map[string]entry
+}
+
+// Start starts a server and points REDIS_HOST and REDIS_PORT to it.
+// The cache package keeps its first connection, start the server before using it.
+func Start() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	os.Setenv("REDIS_HOST", host)
+	os.Setenv("REDIS_PORT", port)
+
+	s := &Server{
+		listener: listener,
+		data:     map[string]entry{},
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Flush deletes every key
+func (s *Server) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = map[string]entry{}
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err = conn.Write([]byte(s.reply(args))); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid command %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if _, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// get returns the entry of key, deleting it once expired
+func (s *Server) get(key string) (entry, bool) {
+	e, ok := s.data[key]
+	if ok && !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		delete(s.data, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func (s *Server) reply(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+
+	case "SET", "SETNX":
+		e := entry{value: args[2]}
+		nx := strings.ToUpper(args[0]) == "SETNX"
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "EX", "PX":
+				if i+1 >= len(args) {
+					return "-ERR syntax error\r\n"
+				}
+				n, _ := strconv.Atoi(args[i+1])
+				unit := time.Second
+				if strings.ToUpper(args[i]) == "PX" {
+					unit = time.Millisecond
+				}
+				e.expireAt = time.Now().Add(time.Duration(n) * unit)
+				i++
+			}
+		}
+		if _, exists := s.get(args[1]); nx && exists {
+			if strings.ToUpper(args[0]) == "SETNX" {
+				return ":0\r\n"
+			}
+			return "$-1\r\n"
+		}
+		s.data[args[1]] = e
+		if strings.ToUpper(args[0]) == "SETNX" {
+			return ":1\r\n"
+		}
+		return "+OK\r\n"
+
+	case "GET":
+		e, ok := s.get(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(e.value), e.value)
+
+	case "EXISTS", "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.get(key); ok {
+				n++
+				if strings.ToUpper(args[0]) == "DEL" {
+					delete(s.data, key)
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+
+	case "EXPIRE":
+		e, ok := s.get(args[1])
+		if !ok {
+			return ":0\r\n"
+		}
+		n, _ := strconv.Atoi(args[2])
+		e.expireAt = time.Now().Add(time.Duration(n) * time.Second)
+		s.data[args[1]] = e
+		return ":1\r\n"
+
+	case "TTL":
+		e, ok := s.get(args[1])
+		if !ok {
+			return ":-2\r\n"
+		}
+		if e.expireAt.IsZero() {
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", int(time.Until(e.expireAt).Seconds()))
+
+	case "FLUSHALL":
+		s.data = map[string]entry{}
+		return "+OK\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%v'\r\n", args[0])
+}
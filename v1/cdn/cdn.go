@@ -19,6 +19,7 @@ type Config struct {
 // Image definition
 type Image struct {
 	Url       string
+	Source    Source // takes precedence over Url when set
 	Resize    string
 	Width     int
 	Height    int
@@ -29,9 +30,11 @@ type Image struct {
 	Extension string
 }
 
+// S3 Amazon S3 object
 type S3 struct {
 	BucketName string
 	Path       string
+	VersionID  string
 }
 
 func New(host, key, salt string) (*Config, error) {
@@ -58,7 +61,11 @@ func (c *Config) GetUrl(img *Image) string {
 	// key := ""
 	// salt := ""
 
-	encodedURL := base64.RawURLEncoding.EncodeToString([]byte(img.Url))
+	sourceURL := img.Url
+	if img.Source != nil {
+		sourceURL = img.Source.SourceURL()
+	}
+	encodedURL := base64.RawURLEncoding.EncodeToString([]byte(sourceURL))
 
 	resize := "fill"
 	width := 0
@@ -97,6 +104,7 @@ func (c *Config) GetUrl(img *Image) string {
 	return fmt.Sprintf("%s/%s%s", c.Host, signature, path)
 }
 
+// GetS3Url returns s3://bucket/path with the path as given, set Image.Source to escape it
 func (c *Config) GetS3Url(s3 *S3) string {
 	return fmt.Sprintf("s3://%s/%s", s3.BucketName, s3.Path)
 }
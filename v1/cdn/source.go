@@ -0,0 +1,91 @@
+package cdn
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Source is an image location that imgproxy knows how to fetch
+type Source interface {
+	SourceURL() string
+}
+
+// GCS Google Cloud Storage object
+type GCS struct {
+	BucketName string
+	Path       string
+	Generation string
+}
+
+// ABS Azure Blob Storage blob
+type ABS struct {
+	Container string
+	Path      string
+	VersionID string
+}
+
+// Local file relative to imgproxy's IMGPROXY_LOCAL_FILESYSTEM_ROOT
+type Local struct {
+	Path string
+}
+
+// HTTP plain http(s) image url
+type HTTP struct {
+	URL string
+}
+
+var (
+	_ Source = (*S3)(nil)
+	_ Source = (*GCS)(nil)
+	_ Source = (*ABS)(nil)
+	_ Source = (*Local)(nil)
+	_ Source = (*HTTP)(nil)
+)
+
+// escapePath escapes each segment of an object path while keeping the separators
+func escapePath(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// objectURL formats scheme://bucket/path with an optional version query
+func objectURL(scheme, bucket, path, version string) string {
+	u := fmt.Sprintf("%s://%s/%s", scheme, bucket, escapePath(path))
+	if version != "" {
+		u += "?" + url.QueryEscape(version)
+	}
+	return u
+}
+
+// SourceURL returns s3://bucket/path[?version_id]
+func (s *S3) SourceURL() string {
+	return objectURL("s3", s.BucketName, s.Path, s.VersionID)
+}
+
+// SourceURL returns gs://bucket/path[?generation]
+func (s *GCS) SourceURL() string {
+	return objectURL("gs", s.BucketName, s.Path, s.Generation)
+}
+
+// SourceURL returns abs://container/path[?version_id]
+func (s *ABS) SourceURL() string {
+	return objectURL("abs", s.Container, s.Path, s.VersionID)
+}
+
+// SourceURL returns local:///path
+func (s *Local) SourceURL() string {
+	return "local:///" + escapePath(s.Path)
+}
+
+// SourceURL returns the url normalized by net/url, or as is if it cannot be parsed
+func (s *HTTP) SourceURL() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return s.URL
+	}
+	return u.String()
+}
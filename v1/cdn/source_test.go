@@ -0,0 +1,70 @@
+package cdn_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/forkyid/go-utils/v1/cdn"
+	"github.com/stretchr/testify/assert"
+)
+
+var sourceURLTestCase = []struct {
+	name     string
+	source   cdn.Source
+	expected string
+}{
+	{
+		name:     "S3",
+		source:   &cdn.S3{BucketName: "bucket.example.com", Path: "users/example/photo.jpg"},
+		expected: "s3://bucket.example.com/users/example/photo.jpg",
+	},
+	{
+		name:     "S3 With Version",
+		source:   &cdn.S3{BucketName: "bucket", Path: "/a b/c#d.jpg", VersionID: "v1"},
+		expected: "s3://bucket/a%20b/c%23d.jpg?v1",
+	},
+	{
+		name:     "GCS With Generation",
+		source:   &cdn.GCS{BucketName: "bucket", Path: "photos/1.jpg", Generation: "1360887697105000"},
+		expected: "gs://bucket/photos/1.jpg?1360887697105000",
+	},
+	{
+		name:     "ABS",
+		source:   &cdn.ABS{Container: "container", Path: "photos/1?.jpg"},
+		expected: "abs://container/photos/1%3F.jpg",
+	},
+	{
+		name:     "Local",
+		source:   &cdn.Local{Path: "/images/my photo.jpg"},
+		expected: "local:///images/my%20photo.jpg",
+	},
+	{
+		name:     "HTTP",
+		source:   &cdn.HTTP{URL: "https://example.com/my photo.jpg"},
+		expected: "https://example.com/my%20photo.jpg",
+	},
+}
+
+func TestSourceURL(t *testing.T) {
+	for _, test := range sourceURLTestCase {
+		assert.Equal(t, test.expected, test.source.SourceURL(), fmt.Sprintf("Unexpected source url on %s", test.name))
+	}
+}
+
+func TestGetUrlWithSource(t *testing.T) {
+	c, err := cdn.New("http://example.com", "6b6579", "73616c74")
+	assert.Nil(t, err)
+
+	s3 := &cdn.S3{BucketName: "bucket", Path: "photo.jpg"}
+	fromSource := c.GetUrl(&cdn.Image{Source: s3, Width: 800})
+	fromUrl := c.GetUrl(&cdn.Image{Url: c.GetS3Url(s3), Width: 800})
+	assert.Equal(t, fromUrl, fromSource)
+}
+
+func TestGetS3UrlUnescaped(t *testing.T) {
+	c, err := cdn.New("http://example.com", "6b6579", "73616c74")
+	assert.Nil(t, err)
+
+	s3 := &cdn.S3{BucketName: "bucket", Path: "my%20photo.jpg", VersionID: "v1"}
+	assert.Equal(t, "s3://bucket/my%20photo.jpg", c.GetS3Url(s3))
+}
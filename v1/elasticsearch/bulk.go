@@ -0,0 +1,310 @@
+package elasticsearch
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/logger"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+)
+
+// bulk indexer defaults
+const (
+	DefaultBulkWorkers       = 1
+	DefaultBulkBatchSize     = 1000
+	DefaultBulkBatchBytes    = 5 << 20
+	DefaultBulkFlushInterval = time.Second
+	DefaultBulkMaxRetries    = 3
+)
+
+var (
+	defaultBulkRetryStatusCodes = []int{
+		http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusServiceUnavailable,
+		http.StatusInsufficientStorage,
+	}
+
+	ErrBulkIndexerClosed = errors.New("bulk indexer closed")
+)
+
+// DeadLetterFunc receives an item that failed permanently,
+// item is nil when the whole bulk request failed
+type DeadLetterFunc func(request elastic.BulkableRequest, item *elastic.BulkResponseItem, err error)
+
+// BulkConfig bulk indexer configuration, zero values fall back to the defaults
+type BulkConfig struct {
+	Name             string
+	Workers          int
+	BatchSize        int // number of actions per bulk request
+	BatchBytes       int // size of a bulk request in bytes
+	FlushInterval    time.Duration
+	MaxRetries       int // retries per failed item
+	Backoff          elastic.Backoff
+	RetryStatusCodes []int // item status codes that are retried
+	DeadLetter       DeadLetterFunc
+}
+
+// BulkStats bulk indexer statistics
+type BulkStats struct {
+	elastic.BulkProcessorStats
+	Retried      int64 // # of items scheduled for retry
+	DeadLettered int64 // # of items given up on
+}
+
+// BulkIndexer wraps elastic.BulkProcessor with per item retries and a dead-letter callback.
+// Add blocks while all workers are busy, which gives callers backpressure.
+// A bulk request that fails as a whole is retried by the processor itself using Backoff,
+// once those retries are exhausted its requests are dead-lettered once. The processor still keeps
+// the batch and sends it again with the next commit.
+type BulkIndexer struct {
+	processor *elastic.BulkProcessor
+	config    BulkConfig
+	retryable map[int]bool
+
+	mu        sync.Mutex
+	settled   *sync.Cond
+	attempts  map[elastic.BulkableRequest]int
+	failed    map[elastic.BulkableRequest]bool // dead-lettered by a failed bulk request, still kept by the processor
+	scheduled int                              // retries waiting for their backoff
+	closed    bool
+
+	retried      int64
+	deadLettered int64
+}
+
+// NewBulkIndexer starts a bulk indexer on client, e.g. the one returned by GetESClient
+func NewBulkIndexer(ctx context.Context, client *elastic.Client, config BulkConfig) (*BulkIndexer, error) {
+	if config.Workers <= 0 {
+		config.Workers = DefaultBulkWorkers
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBulkBatchSize
+	}
+	if config.BatchBytes <= 0 {
+		config.BatchBytes = DefaultBulkBatchBytes
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultBulkFlushInterval
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = DefaultBulkMaxRetries
+	}
+	if config.Backoff == nil {
+		config.Backoff = elastic.NewExponentialBackoff(100*time.Millisecond, 30*time.Second)
+	}
+	if config.RetryStatusCodes == nil {
+		config.RetryStatusCodes = defaultBulkRetryStatusCodes
+	}
+
+	b := &BulkIndexer{
+		config:    config,
+		retryable: map[int]bool{},
+		attempts:  map[elastic.BulkableRequest]int{},
+		failed:    map[elastic.BulkableRequest]bool{},
+	}
+	b.settled = sync.NewCond(&b.mu)
+	for _, code := range config.RetryStatusCodes {
+		b.retryable[code] = true
+	}
+
+	// item retries are handled in after, the processor only retries whole requests
+	processor, err := client.BulkProcessor().
+		Name(config.Name).
+		Workers(config.Workers).
+		BulkActions(config.BatchSize).
+		BulkSize(config.BatchBytes).
+		FlushInterval(config.FlushInterval).
+		Backoff(config.Backoff).
+		RetryItemStatusCodes().
+		Stats(true).
+		After(b.after).
+		Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "start bulk processor")
+	}
+	b.processor = processor
+
+	return b, nil
+}
+
+// Add queues request
+func (b *BulkIndexer) Add(request elastic.BulkableRequest) error {
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return ErrBulkIndexerClosed
+	}
+
+	b.processor.Add(request)
+	return nil
+}
+
+// Index queues an index request
+func (b *BulkIndexer) Index(index, id string, doc interface{}) error {
+	return b.Add(elastic.NewBulkIndexRequest().Index(index).Id(id).Doc(doc))
+}
+
+// Update queues a partial update request
+func (b *BulkIndexer) Update(index, id string, doc interface{}) error {
+	return b.Add(elastic.NewBulkUpdateRequest().Index(index).Id(id).Doc(doc))
+}
+
+// Delete queues a delete request
+func (b *BulkIndexer) Delete(index, id string) error {
+	return b.Add(elastic.NewBulkDeleteRequest().Index(index).Id(id))
+}
+
+// Flush commits queued requests, retries scheduled meanwhile are not waited for
+func (b *BulkIndexer) Flush() error {
+	return b.processor.Flush()
+}
+
+// Stats returns the indexer statistics
+func (b *BulkIndexer) Stats() BulkStats {
+	return BulkStats{
+		BulkProcessorStats: b.processor.Stats(),
+		Retried:            atomic.LoadInt64(&b.retried),
+		DeadLettered:       atomic.LoadInt64(&b.deadLettered),
+	}
+}
+
+// Close stops accepting requests, waits for retries to settle, flushes and stops the workers.
+// The workers are stopped even when flushing fails.
+func (b *BulkIndexer) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	var flushErr error
+	for {
+		b.mu.Lock()
+		for b.scheduled > 0 {
+			b.settled.Wait()
+		}
+		b.mu.Unlock()
+
+		if flushErr = b.processor.Flush(); flushErr != nil {
+			flushErr = errors.Wrap(flushErr, "flush")
+			break
+		}
+
+		b.mu.Lock()
+		settled := b.scheduled == 0 && len(b.attempts) == 0
+		b.mu.Unlock()
+		if settled {
+			break
+		}
+	}
+
+	closeErr := errors.Wrap(b.processor.Close(), "close bulk processor")
+	switch {
+	case flushErr == nil:
+		return closeErr
+	case closeErr == nil:
+		return flushErr
+	}
+	return errors.Errorf("%v; %v", flushErr, closeErr)
+}
+
+// after is called by the processor once a bulk request is done
+func (b *BulkIndexer) after(executionID int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+	if err != nil {
+		// the processor already retried the whole request with Backoff
+		for _, request := range requests {
+			b.mu.Lock()
+			failed := b.failed[request]
+			b.mu.Unlock()
+			if failed {
+				continue
+			}
+			b.deadLetter(request, nil, errors.Wrap(err, "bulk request failed"))
+			b.mu.Lock()
+			b.failed[request] = true
+			b.mu.Unlock()
+		}
+		return
+	}
+	if response == nil {
+		return
+	}
+
+	for i, items := range response.Items {
+		if i >= len(requests) {
+			break
+		}
+		for action, item := range items {
+			if succeeded(action, item) {
+				b.done(requests[i])
+				continue
+			}
+			b.fail(requests[i], item)
+		}
+	}
+}
+
+// fail schedules request for retry, or sends it to the dead-letter callback
+func (b *BulkIndexer) fail(request elastic.BulkableRequest, item *elastic.BulkResponseItem) {
+	b.mu.Lock()
+	b.attempts[request]++
+	attempt := b.attempts[request]
+	b.mu.Unlock()
+
+	if b.retryable[item.Status] {
+		if wait, ok := b.config.Backoff.Next(attempt); ok && attempt <= b.config.MaxRetries {
+			atomic.AddInt64(&b.retried, 1)
+			b.mu.Lock()
+			b.scheduled++
+			b.mu.Unlock()
+
+			time.AfterFunc(wait, func() {
+				b.processor.Add(request)
+				b.mu.Lock()
+				b.scheduled--
+				b.settled.Broadcast()
+				b.mu.Unlock()
+			})
+			return
+		}
+	}
+
+	b.deadLetter(request, item, itemError(item))
+}
+
+// deadLetter gives up on request
+func (b *BulkIndexer) deadLetter(request elastic.BulkableRequest, item *elastic.BulkResponseItem, err error) {
+	b.done(request)
+	atomic.AddInt64(&b.deadLettered, 1)
+	if b.config.DeadLetter != nil {
+		b.config.DeadLetter(request, item, err)
+		return
+	}
+	logger.Warnf("elasticsearch: bulk item dead-lettered", err)
+}
+
+func (b *BulkIndexer) done(request elastic.BulkableRequest) {
+	b.mu.Lock()
+	delete(b.attempts, request)
+	delete(b.failed, request)
+	b.mu.Unlock()
+}
+
+// succeeded treats deleting a missing document as success
+func succeeded(action string, item *elastic.BulkResponseItem) bool {
+	if action == "delete" && item.Status == http.StatusNotFound {
+		return true
+	}
+	return item.Status >= 200 && item.Status <= 299
+}
+
+func itemError(item *elastic.BulkResponseItem) error {
+	if item.Error == nil {
+		return errors.Errorf("status %v", item.Status)
+	}
+	return errors.Errorf("status %v: %v: %v", item.Status, item.Error.Type, item.Error.Reason)
+}
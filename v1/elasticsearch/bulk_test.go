@@ -0,0 +1,111 @@
+package elasticsearch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	es "github.com/forkyid/go-utils/v1/elasticsearch"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+// bulkStatuses answers each bulk index action with the status configured for its id, then 201
+func bulkStatuses(statuses map[string][]int) func(*http.Request) []byte {
+	var mu sync.Mutex
+	return func(r *http.Request) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, _ := ioutil.ReadAll(r.Body)
+		items := []es.MockBulkItem{}
+		lines := bytes.Split(bytes.TrimSpace(body), []byte("\n"))
+		for i := 0; i < len(lines); i += 2 {
+			action := map[string]struct {
+				ID string `json:"_id"`
+			}{}
+			json.Unmarshal(lines[i], &action)
+
+			id := action["index"].ID
+			item := es.MockBulkItem{Action: "index", Index: "posts", ID: id, Status: http.StatusCreated}
+			if len(statuses[id]) > 0 {
+				item.Status, statuses[id] = statuses[id][0], statuses[id][1:]
+				item.Error = http.StatusText(item.Status)
+			}
+			items = append(items, item)
+		}
+
+		response, _ := json.Marshal(es.BulkResponse(items...))
+		return response
+	}
+}
+
+func TestBulkIndexer(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/_bulk").HandlerFunc(bulkStatuses(map[string][]int{
+		"1": {http.StatusTooManyRequests},
+		"2": {http.StatusBadRequest},
+	}))
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+
+	deadLetters := []string{}
+	indexer, err := es.NewBulkIndexer(context.Background(), client, es.BulkConfig{
+		BatchSize: 3,
+		Backoff:   elastic.NewConstantBackoff(time.Millisecond),
+		DeadLetter: func(request elastic.BulkableRequest, item *elastic.BulkResponseItem, err error) {
+			deadLetters = append(deadLetters, item.Id)
+		},
+	})
+	assert.Nil(t, err)
+
+	for _, id := range []string{"1", "2", "3"} {
+		assert.Nil(t, indexer.Index("posts", id, post{Title: id}))
+	}
+	assert.Nil(t, indexer.Close())
+
+	stats := indexer.Stats()
+	assert.Equal(t, []string{"2"}, deadLetters)
+	assert.Equal(t, int64(1), stats.Retried)
+	assert.Equal(t, int64(1), stats.DeadLettered)
+	assert.Equal(t, es.ErrBulkIndexerClosed, indexer.Index("posts", "4", post{}))
+}
+
+func TestBulkIndexerRequestFailed(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	server.Handle(http.MethodPost, "/_bulk").Status(http.StatusInternalServerError).JSON(map[string]string{"error": "unavailable"})
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+
+	var mu sync.Mutex
+	deadLetters := []string{}
+	indexer, err := es.NewBulkIndexer(context.Background(), client, es.BulkConfig{
+		BatchSize: 2,
+		Backoff:   elastic.NewSimpleBackoff(1),
+		DeadLetter: func(request elastic.BulkableRequest, item *elastic.BulkResponseItem, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Nil(t, item)
+			assert.NotNil(t, err)
+			deadLetters = append(deadLetters, request.(*elastic.BulkIndexRequest).String())
+		},
+	})
+	assert.Nil(t, err)
+
+	for _, id := range []string{"1", "2"} {
+		assert.Nil(t, indexer.Index("posts", id, post{Title: id}))
+	}
+	assert.Nil(t, indexer.Close())
+
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, int64(2), indexer.Stats().DeadLettered)
+}
@@ -0,0 +1,255 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+)
+
+// cursor search defaults
+const (
+	DefaultCursorSize      = 100
+	DefaultCursorKeepAlive = "1m"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a search_after position inside a point in time
+type Cursor struct {
+	PitID       string        `json:"p"`
+	SearchAfter []interface{} `json:"a,omitempty"`
+}
+
+// Encode encodes cursor as an opaque url safe token.
+// When key is given the token is encrypted and authenticated with AES-GCM,
+// a blank key uses AES_STRING_KEY. The key must be 16, 24 or 32 bytes.
+func (c Cursor) Encode(key ...string) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal")
+	}
+	if len(key) > 0 {
+		gcm, err := cursorCipher(key[0])
+		if err != nil {
+			return "", err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+			return "", errors.Wrap(err, "nonce")
+		}
+		data = gcm.Seal(nonce, nonce, data, nil)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a token created by Cursor.Encode with the same key
+func DecodeCursor(token string, key ...string) (cursor Cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if len(key) > 0 {
+		gcm, err := cursorCipher(key[0])
+		if err != nil {
+			return Cursor{}, err
+		}
+		if len(data) < gcm.NonceSize() {
+			return Cursor{}, ErrInvalidCursor
+		}
+		nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+		if data, err = gcm.Open(nil, nonce, sealed, nil); err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+	}
+	if json.Unmarshal(data, &cursor) != nil || cursor.PitID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// cursorCipher returns the AES-GCM cipher of key, AES_STRING_KEY when blank
+func cursorCipher(key string) (cipher.AEAD, error) {
+	if key == "" {
+		key = os.Getenv("AES_STRING_KEY")
+	}
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, errors.Wrap(err, "cursor key")
+	}
+	gcm, err := cipher.NewGCM(block)
+	return gcm, errors.Wrap(err, "cursor key")
+}
+
+// CursorSearch pages through every hit of Query with search_after inside a point in time,
+// which is not limited by index.max_result_window.
+// Elasticsearch adds _shard_doc as the implicit tiebreaker to the Sort of point in time searches.
+type CursorSearch struct {
+	Client    *elastic.Client
+	Index     string
+	Query     elastic.Query
+	Sort      []elastic.Sorter
+	Size      int      // hits per page, default DefaultCursorSize
+	KeepAlive string   // point in time keep alive between pages, default DefaultCursorKeepAlive
+	Key       []string // optional aes key for the token
+}
+
+func (s CursorSearch) size() int {
+	if s.Size <= 0 {
+		return DefaultCursorSize
+	}
+	return s.Size
+}
+
+func (s CursorSearch) keepAlive() string {
+	if s.KeepAlive == "" {
+		return DefaultCursorKeepAlive
+	}
+	return s.KeepAlive
+}
+
+// Page returns the hits after token, an empty token starts a new point in time.
+// next is empty after the last page, the point in time is closed then.
+func (s CursorSearch) Page(ctx context.Context, token string) (hits []*elastic.SearchHit, next string, err error) {
+	cursor := Cursor{}
+	if token != "" {
+		if cursor, err = DecodeCursor(token, s.Key...); err != nil {
+			return nil, "", err
+		}
+	} else {
+		pit, err := s.Client.OpenPointInTime(s.Index).KeepAlive(s.keepAlive()).Do(ctx)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "open point in time")
+		}
+		cursor.PitID = pit.Id
+	}
+
+	next, result, err := s.page(ctx, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if result.Hits == nil {
+		return nil, next, nil
+	}
+	return result.Hits.Hits, next, nil
+}
+
+// PageInto decodes the hits after token into target, a pointer to a slice
+func (s CursorSearch) PageInto(ctx context.Context, token string, target interface{}) (next string, err error) {
+	if err = checkSliceTarget(target, nil); err != nil {
+		return "", err
+	}
+
+	hits, next, err := s.Page(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	return next, decodeHits(&elastic.SearchHits{Hits: hits}, target)
+}
+
+func (s CursorSearch) page(ctx context.Context, cursor Cursor) (next string, result *elastic.SearchResult, err error) {
+	service := s.Client.Search().
+		PointInTime(elastic.NewPointInTimeWithKeepAlive(cursor.PitID, s.keepAlive())).
+		SortBy(s.Sort...).
+		Size(s.size())
+	if s.Query != nil {
+		service = service.Query(s.Query)
+	}
+	if len(cursor.SearchAfter) > 0 {
+		service = service.SearchAfter(cursor.SearchAfter...)
+	}
+
+	result, err = service.Do(ctx)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "search")
+	}
+	if result.PitId != "" {
+		cursor.PitID = result.PitId
+	}
+
+	if result.Hits == nil || len(result.Hits.Hits) < s.size() {
+		_, err = s.Client.ClosePointInTime(cursor.PitID).Do(ctx)
+		return "", result, errors.Wrap(err, "close point in time")
+	}
+
+	cursor.SearchAfter = result.Hits.Hits[len(result.Hits.Hits)-1].Sort
+	next, err = cursor.Encode(s.Key...)
+	return next, result, err
+}
+
+// Iterate returns an iterator over every hit, close it if not iterated to the end
+//
+//	it := search.Iterate(ctx)
+//	defer it.Close()
+//	for it.Next() {
+//		hit := it.Hit()
+//	}
+//	if err := it.Err(); err != nil {
+func (s CursorSearch) Iterate(ctx context.Context) *HitIterator {
+	return &HitIterator{ctx: ctx, search: s}
+}
+
+// HitIterator iterates over every hit of a CursorSearch
+type HitIterator struct {
+	ctx     context.Context
+	search  CursorSearch
+	started bool
+	token   string
+	hits    []*elastic.SearchHit
+	hit     *elastic.SearchHit
+	err     error
+}
+
+// Next advances to the next hit, fetching the next page when needed
+func (it *HitIterator) Next() bool {
+	for len(it.hits) == 0 {
+		if it.err != nil || (it.started && it.token == "") {
+			it.hit = nil
+			return false
+		}
+		it.hits, it.token, it.err = it.search.Page(it.ctx, it.token)
+		it.started = true
+	}
+
+	it.hit, it.hits = it.hits[0], it.hits[1:]
+	return true
+}
+
+// Hit returns the current hit
+func (it *HitIterator) Hit() *elastic.SearchHit {
+	return it.hit
+}
+
+// Decode decodes the current hit into target
+func (it *HitIterator) Decode(target interface{}) error {
+	if it.hit == nil {
+		return errors.New("no current hit")
+	}
+	return errors.Wrap(json.Unmarshal(it.hit.Source, target), "unmarshal hit "+it.hit.Id)
+}
+
+// Err returns the error that stopped the iteration
+func (it *HitIterator) Err() error {
+	return it.err
+}
+
+// Close releases the point in time when the iteration stopped early
+func (it *HitIterator) Close() error {
+	if it.token == "" {
+		return nil
+	}
+	cursor, err := DecodeCursor(it.token, it.search.Key...)
+	it.token, it.hits = "", nil
+	if err != nil {
+		return err
+	}
+	_, err = it.search.Client.ClosePointInTime(cursor.PitID).Do(it.ctx)
+	return errors.Wrap(err, "close point in time")
+}
@@ -0,0 +1,105 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	es "github.com/forkyid/go-utils/v1/elasticsearch"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorEncode(t *testing.T) {
+	cursor := es.Cursor{PitID: "pit", SearchAfter: []interface{}{"a", float64(1)}}
+
+	token, err := cursor.Encode()
+	assert.Nil(t, err)
+	decoded, err := es.DecodeCursor(token)
+	assert.Nil(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	_, err = es.DecodeCursor("not a cursor")
+	assert.Equal(t, es.ErrInvalidCursor, err)
+}
+
+func TestCursorEncodeEncrypted(t *testing.T) {
+	key := "0123456789abcdef"
+	cursor := es.Cursor{PitID: "pit", SearchAfter: []interface{}{"a", float64(1)}}
+
+	token, err := cursor.Encode(key)
+	assert.Nil(t, err)
+	decoded, err := es.DecodeCursor(token, key)
+	assert.Nil(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	plain, err := cursor.Encode()
+	assert.Nil(t, err)
+	assert.NotEqual(t, plain, token)
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	assert.Nil(t, err)
+	data[len(data)-1] ^= 1
+	_, err = es.DecodeCursor(base64.RawURLEncoding.EncodeToString(data), key)
+	assert.Equal(t, es.ErrInvalidCursor, err)
+
+	_, err = es.DecodeCursor(token, "fedcba9876543210")
+	assert.Equal(t, es.ErrInvalidCursor, err)
+
+	_, err = cursor.Encode("short")
+	assert.NotNil(t, err)
+
+	t.Setenv("AES_STRING_KEY", "")
+	_, err = cursor.Encode("")
+	assert.NotNil(t, err)
+}
+
+func TestCursorSearchIterate(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+
+	pages := []*elastic.SearchResult{
+		es.SearchResponse("posts", 3,
+			es.MockHit{ID: "1", Source: post{Title: "a"}, Sort: []interface{}{1}},
+			es.MockHit{ID: "2", Source: post{Title: "b"}, Sort: []interface{}{2}},
+		),
+		es.SearchResponse("posts", 3,
+			es.MockHit{ID: "3", Source: post{Title: "c"}, Sort: []interface{}{3}},
+		),
+	}
+	server.Handle(http.MethodPost, "/posts/_pit").Query("keep_alive", "1m").Body([]byte(`{"id":"pit1"}`))
+	server.Handle(http.MethodPost, "/_search").HandlerFunc(func(*http.Request) []byte {
+		page := pages[0]
+		pages = pages[1:]
+		body, _ := json.Marshal(page)
+		return body
+	})
+	server.Handle(http.MethodDelete, "/_pit").Body([]byte(`{"succeeded":true,"num_freed":1}`))
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+
+	it := es.CursorSearch{
+		Client: client,
+		Index:  "posts",
+		Sort:   []elastic.Sorter{elastic.SortInfo{Field: "id", Ascending: true}},
+		Size:   2,
+	}.Iterate(context.Background())
+	defer it.Close()
+
+	titles := []string{}
+	for it.Next() {
+		doc := post{}
+		assert.Nil(t, it.Decode(&doc))
+		titles = append(titles, doc.Title)
+	}
+	assert.Nil(t, it.Err())
+	assert.Equal(t, []string{"a", "b", "c"}, titles)
+
+	requests := server.Requests()
+	assert.Len(t, requests, 4)
+	assert.Contains(t, string(requests[2].Body), `"search_after":[2]`)
+	assert.Equal(t, http.MethodDelete, requests[3].Method)
+}
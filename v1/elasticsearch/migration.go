@@ -0,0 +1,315 @@
+package elasticsearch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/forkyid/go-utils/v1/logger"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrLegacyIndex     = errors.New("alias name is taken by an index")
+	ErrVersionNotFound = errors.New("index version not found")
+)
+
+// IndexMigration moves Alias to a new versioned index, <Alias>_v<Version>.
+// The index is created from Body, documents of the index currently behind Alias are reindexed into it
+// (through Script when set), then Alias is swapped atomically and becomes the write index.
+// Documents written to the previous index during the reindex are copied by a second catch-up reindex
+// after the swap. Documents keep their version so newer writes are never overwritten,
+// deletes during the reindex are not carried over. Until the catch-up succeeds the previous index
+// is marked with the <Alias>_catchup alias, and Run retries the catch-up while the marker exists.
+// A legacy index is deleted by the swap, its catch-up reindex runs right before it instead,
+// stop writers for those to not lose writes.
+// Running it again, or with a Version older than the current one, does nothing,
+// so it is safe to call on every startup.
+type IndexMigration struct {
+	Alias        string
+	Version      int
+	Body         interface{}     // settings and mappings, a string or anything json serializable
+	Script       *elastic.Script // optional, applied to every reindexed document
+	KeepVersions int             // number of previous versions kept for rollback, 0 keeps all
+
+	// MigrateLegacyIndex allows an existing unversioned index named Alias to be reindexed
+	// and then deleted, in the same action that adds the alias
+	MigrateLegacyIndex bool
+}
+
+// VersionedIndex returns <alias>_v<version>
+func VersionedIndex(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+// indexVersion parses the version of a versioned index, -1 if index is not a version of alias
+func indexVersion(alias, index string) int {
+	version, err := strconv.Atoi(strings.TrimPrefix(index, alias+"_v"))
+	if err != nil || !strings.HasPrefix(index, alias+"_v") {
+		return -1
+	}
+	return version
+}
+
+// Run runs the migration
+func (m IndexMigration) Run(ctx context.Context, client *elastic.Client) error {
+	target := VersionedIndex(m.Alias, m.Version)
+
+	current, err := CurrentIndex(ctx, client, m.Alias)
+	if err != nil {
+		return errors.Wrap(err, "current index")
+	}
+	if current == target {
+		return errors.Wrap(m.resumeCatchUp(ctx, client, target), "catch up")
+	}
+	if current != "" && indexVersion(m.Alias, current) >= m.Version {
+		return nil
+	}
+
+	legacy := false
+	if current == "" {
+		exists, err := client.IndexExists(m.Alias).Do(ctx)
+		if err != nil {
+			return errors.Wrap(err, "index exists")
+		}
+		if exists && !m.MigrateLegacyIndex {
+			return errors.Wrap(ErrLegacyIndex, m.Alias)
+		}
+		if exists {
+			legacy, current = true, m.Alias
+		}
+	}
+
+	exists, err := client.IndexExists(target).Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "index exists")
+	}
+	if !exists {
+		service := client.CreateIndex(target)
+		if body, ok := m.Body.(string); ok {
+			service = service.BodyString(body)
+		} else if m.Body != nil {
+			service = service.BodyJson(m.Body)
+		}
+		if _, err = service.Do(ctx); err != nil {
+			return errors.Wrap(err, "create index "+target)
+		}
+	}
+
+	if current != "" {
+		logger.Infof(fmt.Sprintf("elasticsearch: reindexing %v into %v", current, target))
+		if err = m.reindex(ctx, client, current, target); err != nil {
+			return err
+		}
+	}
+
+	if legacy {
+		if err = m.reindex(ctx, client, current, target); err != nil {
+			return err
+		}
+		_, err = client.Alias().
+			Action(
+				elastic.NewAliasRemoveIndexAction(m.Alias),
+				elastic.NewAliasAddAction(m.Alias).Index(target).IsWriteIndex(true),
+			).
+			Do(ctx)
+		if err != nil {
+			return errors.Wrap(err, "replace legacy index")
+		}
+	} else {
+		marker := []elastic.AliasAction{}
+		if current != "" {
+			marker = append(marker, elastic.NewAliasAddAction(catchUpAlias(m.Alias)).Index(current))
+		}
+		if err = swapAlias(ctx, client, m.Alias, target, marker...); err != nil {
+			return errors.Wrap(err, "swap alias")
+		}
+		if err = m.resumeCatchUp(ctx, client, target); err != nil {
+			return errors.Wrap(err, "catch up")
+		}
+	}
+
+	return errors.Wrap(m.prune(ctx, client), "prune")
+}
+
+// catchUpAlias marks the previous indices of alias whose catch-up reindex is pending
+func catchUpAlias(alias string) string {
+	return alias + "_catchup"
+}
+
+// resumeCatchUp reindexes the indices marked by catchUpAlias into target, then removes the marker
+func (m IndexMigration) resumeCatchUp(ctx context.Context, client *elastic.Client, target string) error {
+	marker := catchUpAlias(m.Alias)
+	sources, err := aliasedIndices(ctx, client, marker)
+	if err != nil || len(sources) == 0 {
+		return err
+	}
+
+	for _, source := range sources {
+		logger.Infof(fmt.Sprintf("elasticsearch: catching up %v into %v", source, target))
+		if err = m.reindex(ctx, client, source, target); err != nil {
+			return err
+		}
+	}
+	_, err = client.Alias().Action(elastic.NewAliasRemoveAction(marker).Index(sources...)).Do(ctx)
+	return errors.Wrap(err, "remove catch up marker")
+}
+
+// aliasedIndices returns the indices behind aliases, looked up one by one
+// since a missing alias fails the whole request
+func aliasedIndices(ctx context.Context, client *elastic.Client, aliases ...string) ([]string, error) {
+	indices := []string{}
+	for _, alias := range aliases {
+		result, err := client.Aliases().Alias(alias).Do(ctx)
+		if elastic.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "aliases "+alias)
+		}
+		indices = append(indices, result.IndicesByAlias(alias)...)
+	}
+	return indices, nil
+}
+
+// reindex copies the documents of source into target with their version,
+// documents already in target with the same or a newer version are skipped
+func (m IndexMigration) reindex(ctx context.Context, client *elastic.Client, source, target string) error {
+	service := client.Reindex().
+		SourceIndex(source).
+		Destination(elastic.NewReindexDestination().Index(target).VersionType("external")).
+		ProceedOnVersionConflict().
+		WaitForCompletion(true).
+		Refresh("true")
+	if m.Script != nil {
+		service = service.Script(m.Script)
+	}
+	result, err := service.Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "reindex "+source)
+	}
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("reindex %v: %v documents failed", source, len(result.Failures))
+	}
+	return nil
+}
+
+// prune deletes versions older than KeepVersions before Version,
+// indices Alias currently points to are kept, e.g. after a rollback, as well as those waiting for a catch-up
+func (m IndexMigration) prune(ctx context.Context, client *elastic.Client) error {
+	if m.KeepVersions <= 0 {
+		return nil
+	}
+
+	versions, err := IndexVersions(ctx, client, m.Alias)
+	if err != nil {
+		return err
+	}
+
+	indices, err := aliasedIndices(ctx, client, m.Alias, catchUpAlias(m.Alias))
+	if err != nil {
+		return err
+	}
+	aliased := map[string]bool{}
+	for _, index := range indices {
+		aliased[index] = true
+	}
+
+	old := []string{}
+	for _, version := range versions {
+		index := VersionedIndex(m.Alias, version)
+		if version < m.Version-m.KeepVersions && !aliased[index] {
+			old = append(old, index)
+		}
+	}
+	if len(old) == 0 {
+		return nil
+	}
+
+	_, err = client.DeleteIndex(old...).Do(ctx)
+	return err
+}
+
+// CurrentIndex returns the write index behind alias, empty if alias does not exist
+func CurrentIndex(ctx context.Context, client *elastic.Client, alias string) (string, error) {
+	result, err := client.Aliases().Alias(alias).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	indices := result.IndicesByAlias(alias)
+	if len(indices) == 1 {
+		return indices[0], nil
+	}
+	for index, info := range result.Indices {
+		for _, a := range info.Aliases {
+			if a.AliasName == alias && a.IsWriteIndex {
+				return index, nil
+			}
+		}
+	}
+	if len(indices) > 0 {
+		return "", fmt.Errorf("alias %v points to %v indices without a write index", alias, len(indices))
+	}
+	return "", nil
+}
+
+// IndexVersions returns the existing versions of alias in ascending order
+func IndexVersions(ctx context.Context, client *elastic.Client, alias string) ([]int, error) {
+	result, err := client.IndexGetSettings().Index(alias + "_v*").Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []int{}
+	for index := range result {
+		if version := indexVersion(alias, index); version >= 0 {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// SwapAlias atomically points alias, for reads and writes, to index only
+func SwapAlias(ctx context.Context, client *elastic.Client, alias, index string) error {
+	return swapAlias(ctx, client, alias, index)
+}
+
+// swapAlias swaps alias to index and runs extra in the same atomic action
+func swapAlias(ctx context.Context, client *elastic.Client, alias, index string, extra ...elastic.AliasAction) error {
+	actions := []elastic.AliasAction{}
+
+	indices, err := aliasedIndices(ctx, client, alias)
+	if err != nil {
+		return err
+	}
+	if len(indices) > 0 {
+		actions = append(actions, elastic.NewAliasRemoveAction(alias).Index(indices...))
+	}
+	actions = append(actions, elastic.NewAliasAddAction(alias).Index(index).IsWriteIndex(true))
+	actions = append(actions, extra...)
+
+	_, err = client.Alias().Action(actions...).Do(ctx)
+	return err
+}
+
+// RollbackIndex points alias back to a kept version
+func RollbackIndex(ctx context.Context, client *elastic.Client, alias string, version int) error {
+	index := VersionedIndex(alias, version)
+	exists, err := client.IndexExists(index).Do(ctx)
+	if err != nil {
+		return errors.Wrap(err, "index exists")
+	}
+	if !exists {
+		return errors.Wrap(ErrVersionNotFound, index)
+	}
+
+	return errors.Wrap(SwapAlias(ctx, client, alias, index), "swap alias")
+}
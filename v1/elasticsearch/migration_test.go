@@ -0,0 +1,126 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	es "github.com/forkyid/go-utils/v1/elasticsearch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexMigrationRun(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	server.Handle(http.MethodGet, "/_alias/posts").Body([]byte(`{"posts_v1":{"aliases":{"posts":{}}}}`))
+	server.Handle(http.MethodGet, "/_alias/posts_catchup").Body([]byte(`{"posts_v1":{"aliases":{"posts_catchup":{}}}}`))
+	server.Handle(http.MethodHead, "/posts_v2").Status(http.StatusNotFound)
+	server.Handle(http.MethodPut, "/posts_v2").Body([]byte(`{"acknowledged":true,"index":"posts_v2"}`))
+	server.Handle(http.MethodPost, "/_reindex").Body([]byte(`{"total":1,"created":1,"failures":[]}`))
+	server.Handle(http.MethodPost, "/_aliases").Body([]byte(`{"acknowledged":true}`))
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+
+	err = es.IndexMigration{
+		Alias:   "posts",
+		Version: 2,
+		Body:    `{"mappings":{"properties":{"title":{"type":"text"}}}}`,
+	}.Run(context.Background(), client)
+	assert.Nil(t, err)
+
+	paths := []string{}
+	for _, request := range server.Requests() {
+		paths = append(paths, request.Method+" "+request.Path)
+	}
+	assert.Equal(t, []string{
+		"GET /_alias/posts",
+		"HEAD /posts_v2",
+		"PUT /posts_v2",
+		"POST /_reindex",
+		"GET /_alias/posts",
+		"POST /_aliases",
+		"GET /_alias/posts_catchup",
+		"POST /_reindex",
+		"POST /_aliases",
+	}, paths)
+
+	reindex := string(server.Requests()[3].Body)
+	assert.Contains(t, reindex, `"version_type":"external"`)
+	assert.Contains(t, reindex, `"conflicts":"proceed"`)
+	assert.Equal(t, reindex, string(server.Requests()[7].Body))
+	assert.Contains(t, string(server.Requests()[8].Body), `"remove":{"alias":"posts_catchup","index":"posts_v1"}`)
+
+	aliases := string(server.Requests()[5].Body)
+	assert.Contains(t, aliases, `"add":{"alias":"posts_catchup","index":"posts_v1"}`)
+	assert.Contains(t, aliases, `"remove":{"alias":"posts","index":"posts_v1"}`)
+	assert.Contains(t, aliases, `"add":{"alias":"posts","index":"posts_v2","is_write_index":true}`)
+}
+
+func TestIndexMigrationPruneKeepsAliasedIndices(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	// posts_v1 is still behind the alias, e.g. rolled back to while migrating
+	server.Handle(http.MethodGet, "/_alias/posts").Body([]byte(`{"posts_v1":{"aliases":{"posts":{}}}}`))
+	server.Handle(http.MethodHead, "/posts_v3").Status(http.StatusNotFound)
+	server.Handle(http.MethodPut, "/posts_v3").Body([]byte(`{"acknowledged":true,"index":"posts_v3"}`))
+	server.Handle(http.MethodPost, "/_reindex").Body([]byte(`{"total":1,"created":1,"failures":[]}`))
+	server.Handle(http.MethodPost, "/_aliases").Body([]byte(`{"acknowledged":true}`))
+	server.Handle(http.MethodGet, "/*/_settings").Body([]byte(`{"posts_v0":{},"posts_v1":{},"posts_v2":{},"posts_v3":{}}`))
+	server.Handle(http.MethodDelete, "/*").Body([]byte(`{"acknowledged":true}`))
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+
+	err = es.IndexMigration{Alias: "posts", Version: 3, KeepVersions: 1}.Run(context.Background(), client)
+	assert.Nil(t, err)
+
+	deleted := []string{}
+	for _, request := range server.Requests() {
+		if request.Method == http.MethodDelete {
+			deleted = append(deleted, request.Path)
+		}
+	}
+	assert.Equal(t, []string{"/posts_v0"}, deleted)
+}
+
+func TestIndexMigrationResumesCatchUp(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	// the previous run swapped the alias but failed to catch up
+	server.Handle(http.MethodGet, "/_alias/posts").Body([]byte(`{"posts_v2":{"aliases":{"posts":{"is_write_index":true}}}}`))
+	server.Handle(http.MethodGet, "/_alias/posts_catchup").Body([]byte(`{"posts_v1":{"aliases":{"posts_catchup":{}}}}`))
+	server.Handle(http.MethodPost, "/_reindex").Body([]byte(`{"total":1,"created":1,"failures":[]}`))
+	server.Handle(http.MethodPost, "/_aliases").Body([]byte(`{"acknowledged":true}`))
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+
+	err = es.IndexMigration{Alias: "posts", Version: 2}.Run(context.Background(), client)
+	assert.Nil(t, err)
+
+	paths := []string{}
+	for _, request := range server.Requests() {
+		paths = append(paths, request.Method+" "+request.Path)
+	}
+	assert.Equal(t, []string{
+		"GET /_alias/posts",
+		"GET /_alias/posts_catchup",
+		"POST /_reindex",
+		"POST /_aliases",
+	}, paths)
+	assert.Contains(t, string(server.Requests()[2].Body), `"source":{"index":"posts_v1"}`)
+}
+
+func TestIndexMigrationRunAlreadyMigrated(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	server.Handle(http.MethodGet, "/_alias/posts").Body([]byte(`{"posts_v3":{"aliases":{"posts":{}}}}`))
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+
+	err = es.IndexMigration{Alias: "posts", Version: 2}.Run(context.Background(), client)
+	assert.Nil(t, err)
+	assert.Len(t, server.Requests(), 1)
+}
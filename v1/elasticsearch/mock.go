@@ -0,0 +1,352 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"sync"
+
+	elastic "github.com/olivere/elastic/v7"
+)
+
+// mockURL is used by clients whose requests never leave the process
+const mockURL = "http://elasticsearch.mock:9200"
+
+// MockRequest is a request received by the mock
+type MockRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// MockRoute canned response for requests matching method, path pattern and query
+type MockRoute struct {
+	method  string
+	pattern string
+	exact   bool
+	query   url.Values
+	status  int
+	header  http.Header
+	handler func(*http.Request) []byte
+}
+
+// Query requires query parameter key to have value, call it again for each value of a repeated parameter
+func (route *MockRoute) Query(key, value string) *MockRoute {
+	route.query.Add(key, value)
+	return route
+}
+
+// Status sets the response status code, default 200
+func (route *MockRoute) Status(code int) *MockRoute {
+	route.status = code
+	return route
+}
+
+// Header sets a response header
+func (route *MockRoute) Header(key, value string) *MockRoute {
+	route.header.Set(key, value)
+	return route
+}
+
+// Body responds with raw body
+func (route *MockRoute) Body(body []byte) *MockRoute {
+	return route.HandlerFunc(func(*http.Request) []byte { return body })
+}
+
+// JSON responds with v encoded as json
+func (route *MockRoute) JSON(v interface{}) *MockRoute {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic("elasticsearch mock: marshal response: " + err.Error())
+	}
+	return route.Body(body)
+}
+
+// HandlerFunc responds with the body returned by handler
+func (route *MockRoute) HandlerFunc(handler func(*http.Request) []byte) *MockRoute {
+	route.handler = handler
+	return route
+}
+
+func (route *MockRoute) match(r *http.Request) bool {
+	if route.method != "" && route.method != r.Method {
+		return false
+	}
+	if route.exact && route.pattern != r.URL.Path {
+		return false
+	}
+	if ok, _ := path.Match(route.pattern, r.URL.Path); !route.exact && !ok {
+		return false
+	}
+	query := r.URL.Query()
+	for key, values := range route.query {
+		for _, value := range values {
+			if !containsString(query[key], value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// same reports whether other was added for the same requests as route
+func (route *MockRoute) same(other *MockRoute) bool {
+	return route.method == other.method && route.pattern == other.pattern &&
+		route.exact == other.exact && reflect.DeepEqual(route.query, other.query)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// DummyHTTPClient for mocking es responses, routes are matched in the order they are added.
+// A route added again with the same method, pattern and query replaces the earlier one.
+type DummyHTTPClient struct {
+	mu       sync.Mutex
+	routes   []*MockRoute
+	requests []MockRequest
+}
+
+// Handle adds route for method and path pattern (path.Match syntax, e.g. "/posts/_doc/*").
+// An empty method matches any method.
+func (c *DummyHTTPClient) Handle(method, pattern string) *MockRoute {
+	return c.add(method, pattern, false)
+}
+
+func (c *DummyHTTPClient) add(method, pattern string, exact bool) *MockRoute {
+	route := &MockRoute{
+		method:  method,
+		pattern: pattern,
+		exact:   exact,
+		query:   url.Values{},
+		status:  http.StatusOK,
+		header:  http.Header{"Content-Type": []string{"application/json"}},
+		handler: func(*http.Request) []byte { return []byte("{}") },
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routes = append(c.routes, route)
+	return route
+}
+
+// HandleFunc adds handler for a specific url, matched exactly for any method
+func (c *DummyHTTPClient) HandleFunc(url string, handler func(*http.Request) []byte) {
+	c.add("", url, true).HandlerFunc(handler)
+}
+
+// Requests returns the requests received so far
+func (c *DummyHTTPClient) Requests() []MockRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]MockRequest{}, c.requests...)
+}
+
+// Reset removes all routes and recorded requests
+func (c *DummyHTTPClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routes = nil
+	c.requests = nil
+}
+
+// ServeHTTP records the request and writes the first matching route, or 404
+func (c *DummyHTTPClient) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	c.mu.Lock()
+	c.requests = append(c.requests, MockRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	var route *MockRoute
+	for _, candidate := range c.routes {
+		if route == nil && candidate.match(r) || route != nil && route.same(candidate) {
+			route = candidate
+		}
+	}
+	c.mu.Unlock()
+
+	if route == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(elastic.Error{
+			Status: http.StatusNotFound,
+			Details: &elastic.ErrorDetails{
+				Type:   "mock_route_not_found",
+				Reason: r.Method + " " + r.URL.String(),
+			},
+		})
+		return
+	}
+
+	for key, values := range route.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(route.status)
+	w.Write(route.handler(r))
+}
+
+// Do for handling request without a listening server
+func (c *DummyHTTPClient) Do(r *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	c.ServeHTTP(recorder, r)
+	return recorder.Result(), nil
+}
+
+// NewMockHTTPClient for handling responseMock
+func NewMockHTTPClient() *DummyHTTPClient {
+	return &DummyHTTPClient{}
+}
+
+// DummyElasticSearchClient returning *elastic.Client, error
+func DummyElasticSearchClient(httpClient *DummyHTTPClient) (*elastic.Client, error) {
+	return elastic.NewSimpleClient(
+		elastic.SetURL(mockURL),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+		elastic.SetHttpClient(httpClient))
+}
+
+// MockServer serves mocked routes on a local listener, close it when done
+type MockServer struct {
+	*DummyHTTPClient
+	Server *httptest.Server
+}
+
+// NewMockServer starts a mock server
+//
+//	server := elasticsearch.NewMockServer()
+//	defer server.Close()
+//	server.Handle(http.MethodGet, "/posts/_doc/*").JSON(elasticsearch.GetResponse("posts", "1", post))
+//	client, _ := server.NewClient()
+func NewMockServer() *MockServer {
+	router := NewMockHTTPClient()
+	return &MockServer{
+		DummyHTTPClient: router,
+		Server:          httptest.NewServer(router),
+	}
+}
+
+// URL returns the server base url
+func (s *MockServer) URL() string {
+	return s.Server.URL
+}
+
+// NewClient creates a client connected to the server
+func (s *MockServer) NewClient(options ...elastic.ClientOptionFunc) (*elastic.Client, error) {
+	return elastic.NewSimpleClient(append([]elastic.ClientOptionFunc{
+		elastic.SetURL(s.Server.URL),
+		elastic.SetSniff(false),
+		elastic.SetHealthcheck(false),
+	}, options...)...)
+}
+
+// Close shuts the server down
+func (s *MockServer) Close() {
+	s.Server.Close()
+}
+
+// MockHit search hit for SearchResponse
+type MockHit struct {
+	ID     string
+	Source interface{}
+	Sort   []interface{}
+}
+
+// SearchResponse builds a search response body with total hits
+func SearchResponse(index string, total int64, hits ...MockHit) *elastic.SearchResult {
+	result := &elastic.SearchResult{
+		Shards: &elastic.ShardsInfo{Total: 1, Successful: 1},
+		Hits: &elastic.SearchHits{
+			TotalHits: &elastic.TotalHits{Value: total, Relation: "eq"},
+			Hits:      make([]*elastic.SearchHit, len(hits)),
+		},
+	}
+	for i, hit := range hits {
+		result.Hits.Hits[i] = &elastic.SearchHit{
+			Index:  index,
+			Id:     hit.ID,
+			Source: mustMarshal(hit.Source),
+			Sort:   hit.Sort,
+		}
+	}
+	return result
+}
+
+// GetResponse builds a get response body, a nil source builds a not found response
+func GetResponse(index, id string, source interface{}) *elastic.GetResult {
+	result := &elastic.GetResult{
+		Index: index,
+		Type:  "_doc",
+		Id:    id,
+	}
+	if source != nil {
+		result.Found = true
+		result.Source = mustMarshal(source)
+	}
+	return result
+}
+
+// MockBulkItem bulk item result for BulkResponse, status defaults to 200
+type MockBulkItem struct {
+	Action string
+	Index  string
+	ID     string
+	Status int
+	Error  string
+}
+
+// BulkResponse builds a bulk response body, errors is set when any item has an error
+func BulkResponse(items ...MockBulkItem) *elastic.BulkResponse {
+	response := &elastic.BulkResponse{
+		Items: make([]map[string]*elastic.BulkResponseItem, len(items)),
+	}
+	for i, item := range items {
+		result := &elastic.BulkResponseItem{
+			Index:  item.Index,
+			Type:   "_doc",
+			Id:     item.ID,
+			Status: item.Status,
+		}
+		if result.Status == 0 {
+			result.Status = http.StatusOK
+		}
+		if item.Error != "" {
+			response.Errors = true
+			result.Error = &elastic.ErrorDetails{Type: "mock_exception", Reason: item.Error}
+		}
+		response.Items[i] = map[string]*elastic.BulkResponseItem{item.Action: result}
+	}
+	return response
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic("elasticsearch mock: marshal source: " + err.Error())
+	}
+	return body
+}
@@ -0,0 +1,67 @@
+package elasticsearch_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	es "github.com/forkyid/go-utils/v1/elasticsearch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockRouteQuery(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	server.Handle(http.MethodGet, "/posts").Query("tag", "a").Query("tag", "b").Status(http.StatusOK)
+	server.Handle(http.MethodGet, "/posts").Status(http.StatusTeapot)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "repeated", query: "?tag=a&tag=b", wantStatus: http.StatusOK},
+		{name: "repeated in another order", query: "?tag=b&tag=a", wantStatus: http.StatusOK},
+		{name: "missing value", query: "?tag=b", wantStatus: http.StatusTeapot},
+		{name: "missing key", wantStatus: http.StatusTeapot},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := http.Get(server.URL() + "/posts" + test.query)
+			assert.Nil(t, err)
+			response.Body.Close()
+			assert.Equal(t, test.wantStatus, response.StatusCode)
+		})
+	}
+}
+
+func TestMockHandleFunc(t *testing.T) {
+	server := es.NewMockServer()
+	defer server.Close()
+	server.HandleFunc("/posts/_doc/[1]", func(*http.Request) []byte { return []byte(`{"v":1}`) })
+	server.HandleFunc("/posts/_doc/[1]", func(*http.Request) []byte { return []byte(`{"v":2}`) })
+	server.Handle(http.MethodGet, "/posts/_doc/*").Status(http.StatusTeapot)
+	server.Handle(http.MethodGet, "/posts/_doc/*").Status(http.StatusAccepted)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "exact url replaced", path: "/posts/_doc/[1]", wantStatus: http.StatusOK, wantBody: `{"v":2}`},
+		{name: "not a pattern", path: "/posts/_doc/1", wantStatus: http.StatusAccepted, wantBody: "{}"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := http.Get(server.URL() + (&url.URL{Path: test.path}).EscapedPath())
+			assert.Nil(t, err)
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, test.wantStatus, response.StatusCode)
+			assert.Equal(t, test.wantBody, string(body))
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package elasticsearch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/util/env"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+)
+
+// registered client names
+const (
+	DefaultClient = "default"
+	AIClient      = "ai"
+)
+
+// Config elasticsearch client configuration
+type Config struct {
+	URLs                  []string
+	Username              string
+	Password              string
+	APIKey                string
+	TLSCAFile             string
+	TLSInsecureSkipVerify bool
+	Sniff                 bool
+	Healthcheck           bool
+	MaxRetries            int
+	Gzip                  bool
+}
+
+type registration struct {
+	load   func() Config
+	mu     sync.Mutex
+	client *elastic.Client
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*registration{}
+
+	ErrClientNotRegistered = errors.New("elasticsearch client not registered")
+)
+
+func init() {
+	RegisterEnv(DefaultClient, "ELASTICSEARCH")
+	RegisterEnv(AIClient, "AI_ELASTICSEARCH")
+}
+
+// ConfigFromEnv reads config from environment variables sharing prefix:
+//
+//	<PREFIX>_HOST                       comma separated urls
+//	<PREFIX>_USERNAME                   basic auth username
+//	<PREFIX>_PASSWORD                   basic auth password
+//	<PREFIX>_API_KEY                    base64 encoded "id:api_key"
+//	<PREFIX>_TLS_CA_FILE                PEM encoded CA certificates
+//	<PREFIX>_TLS_INSECURE_SKIP_VERIFY   default false
+//	<PREFIX>_SNIFF                      default false
+//	<PREFIX>_HEALTHCHECK                default false
+//	<PREFIX>_MAX_RETRIES                default 0
+//	<PREFIX>_GZIP                       default false
+func ConfigFromEnv(prefix string) Config {
+	config := Config{
+		Username:              env.GetStr(prefix + "_USERNAME"),
+		Password:              env.GetStr(prefix + "_PASSWORD"),
+		APIKey:                env.GetStr(prefix + "_API_KEY"),
+		TLSCAFile:             env.GetStr(prefix + "_TLS_CA_FILE"),
+		TLSInsecureSkipVerify: env.GetBool(prefix + "_TLS_INSECURE_SKIP_VERIFY"),
+		Sniff:                 env.GetBool(prefix + "_SNIFF"),
+		Healthcheck:           env.GetBool(prefix + "_HEALTHCHECK"),
+		MaxRetries:            env.GetInt(prefix + "_MAX_RETRIES"),
+		Gzip:                  env.GetBool(prefix + "_GZIP"),
+	}
+	for _, url := range strings.Split(env.GetStr(prefix+"_HOST"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			config.URLs = append(config.URLs, url)
+		}
+	}
+	return config
+}
+
+// Register registers a named client, replacing any previous registration.
+// The client is built once on first use.
+func Register(name string, config Config) {
+	register(name, func() Config { return config })
+}
+
+// RegisterEnv registers a named client configured by ConfigFromEnv(prefix).
+// Environment is read on first use, not on registration.
+func RegisterEnv(name, prefix string) {
+	register(name, func() Config { return ConfigFromEnv(prefix) })
+}
+
+func register(name string, load func() Config) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = &registration{load: load}
+}
+
+// Client returns the named client, creating it on first successful call.
+// Creation is retried on the next call after an error.
+func Client(name string) (*elastic.Client, error) {
+	registryMu.RLock()
+	reg, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, errors.Wrap(ErrClientNotRegistered, name)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.client != nil {
+		return reg.client, nil
+	}
+
+	client, err := NewClient(reg.load())
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	reg.client = client
+	return client, nil
+}
+
+// NewClient creates a new client from config without registering it
+func NewClient(config Config) (*elastic.Client, error) {
+	httpClient, err := config.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	options := []elastic.ClientOptionFunc{
+		elastic.SetURL(config.URLs...),
+		elastic.SetSniff(config.Sniff),
+		elastic.SetHealthcheck(config.Healthcheck),
+		elastic.SetGzip(config.Gzip),
+		elastic.SetHttpClient(httpClient),
+	}
+	if config.Username != "" || config.Password != "" {
+		options = append(options, elastic.SetBasicAuth(config.Username, config.Password))
+	}
+	if config.APIKey != "" {
+		options = append(options, elastic.SetHeaders(http.Header{
+			"Authorization": []string{"ApiKey " + config.APIKey},
+		}))
+	}
+	if config.MaxRetries > 0 {
+		options = append(options, elastic.SetRetrier(&maxRetrier{
+			max:     config.MaxRetries,
+			backoff: elastic.NewExponentialBackoff(100*time.Millisecond, 5*time.Second),
+		}))
+	}
+
+	return elastic.NewClient(options...)
+}
+
+func (config Config) httpClient() (*http.Client, error) {
+	if config.TLSCAFile == "" && !config.TLSInsecureSkipVerify {
+		return http.DefaultClient, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.TLSInsecureSkipVerify,
+	}
+	if config.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read ca file")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %v", config.TLSCAFile)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// maxRetrier retries with backoff up to max times
+type maxRetrier struct {
+	max     int
+	backoff elastic.Backoff
+}
+
+func (r *maxRetrier) Retry(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error) {
+	if retry > r.max {
+		return 0, false, nil
+	}
+	wait, ok := r.backoff.Next(retry)
+	return wait, ok, nil
+}
@@ -0,0 +1,29 @@
+package elasticsearch_test
+
+import (
+	"os"
+	"testing"
+
+	es "github.com/forkyid/go-utils/v1/elasticsearch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRetriesAfterError(t *testing.T) {
+	os.Setenv("RETRY_ES_HOST", "http://localhost:9200")
+	os.Setenv("RETRY_ES_TLS_CA_FILE", "missing-ca.pem")
+	defer os.Unsetenv("RETRY_ES_HOST")
+	defer os.Unsetenv("RETRY_ES_TLS_CA_FILE")
+	es.RegisterEnv("retry", "RETRY_ES")
+
+	_, err := es.Client("retry")
+	assert.NotNil(t, err)
+
+	os.Unsetenv("RETRY_ES_TLS_CA_FILE")
+	client, err := es.Client("retry")
+	assert.Nil(t, err)
+	assert.NotNil(t, client)
+
+	cached, err := es.Client("retry")
+	assert.Nil(t, err)
+	assert.Same(t, client, cached)
+}
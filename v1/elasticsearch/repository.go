@@ -0,0 +1,204 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/forkyid/go-utils/v1/pagination"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotFound      = errors.New("document not found")
+	ErrInvalidTarget = errors.New("invalid target")
+)
+
+// Repository wraps an index holding documents of a single go type
+type Repository struct {
+	client  *elastic.Client
+	index   string
+	docType reflect.Type
+}
+
+// NewRepository creates repository for index, doc is a zero value of the document type
+//
+//	repo := elasticsearch.NewRepository(client, "posts", Post{})
+func NewRepository(client *elastic.Client, index string, doc interface{}) *Repository {
+	docType := reflect.TypeOf(doc)
+	if docType != nil && docType.Kind() == reflect.Ptr {
+		docType = docType.Elem()
+	}
+	return &Repository{
+		client:  client,
+		index:   index,
+		docType: docType,
+	}
+}
+
+// IndexName returns the index name
+func (r *Repository) IndexName() string {
+	return r.index
+}
+
+// Client returns the underlying client
+func (r *Repository) Client() *elastic.Client {
+	return r.client
+}
+
+// checkTarget validates that target is a pointer to the document type
+func (r *Repository) checkTarget(target interface{}) error {
+	t := reflect.TypeOf(target)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return errors.Wrap(ErrInvalidTarget, "target is not a pointer")
+	}
+	if r.docType != nil && t.Elem() != r.docType {
+		return errors.Wrap(ErrInvalidTarget, fmt.Sprintf("expected *%v, got %v", r.docType, t))
+	}
+	return nil
+}
+
+// Get decodes document by id into target, returns ErrNotFound if missing
+func (r *Repository) Get(ctx context.Context, id string, target interface{}) error {
+	if err := r.checkTarget(target); err != nil {
+		return err
+	}
+
+	result, err := r.client.Get().
+		Index(r.index).
+		Id(id).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "get")
+	}
+	if !result.Found {
+		return ErrNotFound
+	}
+
+	return errors.Wrap(json.Unmarshal(result.Source, target), "unmarshal")
+}
+
+// Index creates or replaces document by id, an empty id lets elasticsearch generate one
+func (r *Repository) Index(ctx context.Context, id string, doc interface{}) (string, error) {
+	service := r.client.Index().
+		Index(r.index).
+		BodyJson(doc)
+	if id != "" {
+		service = service.Id(id)
+	}
+
+	result, err := service.Do(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "index")
+	}
+	return result.Id, nil
+}
+
+// Update partially updates document by id, doc holds only the changed fields
+func (r *Repository) Update(ctx context.Context, id string, doc interface{}) error {
+	_, err := r.client.Update().
+		Index(r.index).
+		Id(id).
+		Doc(doc).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return ErrNotFound
+	}
+	return errors.Wrap(err, "update")
+}
+
+// Delete deletes document by id
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	_, err := r.client.Delete().
+		Index(r.index).
+		Id(id).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return ErrNotFound
+	}
+	return errors.Wrap(err, "delete")
+}
+
+// Search decodes the hits into target, a pointer to a slice of the document type (or of pointers to it).
+// When p is not nil, From/Size are taken from p.Offset/p.Limit and p.TotalData/p.TotalPage are set from hits.total.
+func (r *Repository) Search(ctx context.Context, query elastic.Query, p *pagination.Pagination, target interface{}, sorters ...elastic.Sorter) (total int64, err error) {
+	if err = checkSliceTarget(target, r.docType); err != nil {
+		return 0, err
+	}
+
+	service := r.client.Search(r.index).
+		TrackTotalHits(true).
+		SortBy(sorters...)
+	if query != nil {
+		service = service.Query(query)
+	}
+	if p != nil {
+		p.Paginate()
+		service = service.From(p.Offset).Size(p.Limit)
+	}
+
+	result, err := service.Do(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "search")
+	}
+
+	if err = decodeHits(result.Hits, target); err != nil {
+		return 0, err
+	}
+
+	total = result.TotalHits()
+	if p != nil {
+		p.TotalData = int(total)
+		p.SetTotalPage()
+	}
+	return total, nil
+}
+
+// checkSliceTarget validates that target is a pointer to a slice of docType or of pointers to it
+func checkSliceTarget(target interface{}, docType reflect.Type) error {
+	t := reflect.TypeOf(target)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return errors.Wrap(ErrInvalidTarget, "target is not a pointer to slice")
+	}
+	elemType := t.Elem().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if docType != nil && elemType != docType {
+		return errors.Wrap(ErrInvalidTarget, fmt.Sprintf("expected slice of %v, got %v", docType, t.Elem()))
+	}
+	return nil
+}
+
+// decodeHits replaces the content of target, a pointer to slice, with the decoded hits
+func decodeHits(hits *elastic.SearchHits, target interface{}) error {
+	slice := reflect.ValueOf(target).Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+
+	decoded := reflect.MakeSlice(slice.Type(), 0, 0)
+	if hits == nil {
+		slice.Set(decoded)
+		return nil
+	}
+	for _, hit := range hits.Hits {
+		doc := reflect.New(elemType)
+		if err := json.Unmarshal(hit.Source, doc.Interface()); err != nil {
+			return errors.Wrap(err, "unmarshal hit "+hit.Id)
+		}
+		if !isPtr {
+			doc = doc.Elem()
+		}
+		decoded = reflect.Append(decoded, doc)
+	}
+	slice.Set(decoded)
+	return nil
+}
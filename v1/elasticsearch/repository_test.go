@@ -0,0 +1,76 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	es "github.com/forkyid/go-utils/v1/elasticsearch"
+	"github.com/forkyid/go-utils/v1/pagination"
+	elastic "github.com/olivere/elastic/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+type post struct {
+	Title string `json:"title"`
+}
+
+func newRepository(t *testing.T) (*es.MockServer, *es.Repository) {
+	server := es.NewMockServer()
+	t.Cleanup(server.Close)
+
+	client, err := server.NewClient()
+	assert.Nil(t, err)
+	return server, es.NewRepository(client, "posts", post{})
+}
+
+func TestRepositoryGet(t *testing.T) {
+	server, repo := newRepository(t)
+	server.Handle(http.MethodGet, "/posts/_doc/1").JSON(es.GetResponse("posts", "1", post{Title: "hello"}))
+	server.Handle(http.MethodGet, "/posts/_doc/*").Status(http.StatusNotFound).JSON(es.GetResponse("posts", "2", nil))
+
+	doc := post{}
+	assert.Nil(t, repo.Get(context.Background(), "1", &doc))
+	assert.Equal(t, "hello", doc.Title)
+
+	assert.Equal(t, es.ErrNotFound, repo.Get(context.Background(), "2", &doc))
+
+	err := repo.Get(context.Background(), "1", &map[string]interface{}{})
+	assert.ErrorIs(t, err, es.ErrInvalidTarget)
+}
+
+func TestRepositorySearch(t *testing.T) {
+	server, repo := newRepository(t)
+	server.Handle(http.MethodPost, "/posts/_search").
+		JSON(es.SearchResponse("posts", 25,
+			es.MockHit{ID: "11", Source: post{Title: "a"}},
+			es.MockHit{ID: "12", Source: post{Title: "b"}},
+		))
+
+	p := &pagination.Pagination{Page: 2, Limit: 10}
+	docs := []*post{}
+	total, err := repo.Search(context.Background(), elastic.NewMatchAllQuery(), p, &docs)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25), total)
+	assert.Len(t, docs, 2)
+	assert.Equal(t, "b", docs[1].Title)
+	assert.Equal(t, 25, p.TotalData)
+	assert.Equal(t, 3, p.TotalPage)
+
+	requests := server.Requests()
+	assert.Len(t, requests, 1)
+	assert.Contains(t, string(requests[0].Body), `"from":10`)
+	assert.Contains(t, string(requests[0].Body), `"size":10`)
+}
+
+func TestDummyElasticSearchClient(t *testing.T) {
+	httpClient := es.NewMockHTTPClient()
+	httpClient.Handle(http.MethodDelete, "/posts/_doc/1").Status(http.StatusNotFound).JSON(map[string]string{"result": "not_found"})
+
+	client, err := es.DummyElasticSearchClient(httpClient)
+	assert.Nil(t, err)
+
+	repo := es.NewRepository(client, "posts", post{})
+	assert.Equal(t, es.ErrNotFound, repo.Delete(context.Background(), "1"))
+	assert.Equal(t, http.MethodDelete, httpClient.Requests()[0].Method)
+}
@@ -0,0 +1,185 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/logger"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultRefreshInterval = 30 * time.Second
+
+	// TargetContextKey holds the Target in a gin context
+	TargetContextKey = "feature_flag_target"
+)
+
+// Source loads every flag definition
+type Source interface {
+	Flags(ctx context.Context) ([]Flag, error)
+}
+
+type httpSource struct {
+	url string
+}
+
+// NewHTTPSource loads flags from the result of GET url
+func NewHTTPSource(url string) Source {
+	return &httpSource{url: url}
+}
+
+// NewDefaultSource loads flags from the flag service at API_ORIGIN_URL
+func NewDefaultSource() Source {
+	return NewHTTPSource(fmt.Sprintf("%v/flag/v1/flags", os.Getenv("API_ORIGIN_URL")))
+}
+
+func (s *httpSource) Flags(ctx context.Context) (flags []Flag, err error) {
+	req := rest.Request{
+		URL:     s.url,
+		Method:  http.MethodGet,
+		Context: ctx,
+	}
+
+	body, code := req.Send()
+	if code != http.StatusOK {
+		err = fmt.Errorf("[%v] %v: %v", req.Method, req.URL, string(body))
+		return
+	}
+
+	data, err := rest.GetData(body)
+	if err != nil {
+		err = errors.Wrap(err, "get data")
+		return
+	}
+
+	err = json.Unmarshal(data, &flags)
+	err = errors.Wrap(err, "unmarshal data")
+	return
+}
+
+// Config flag client configuration
+type Config struct {
+	RefreshInterval time.Duration // default DefaultRefreshInterval
+	// Defaults are used for flags missing from the source, e.g. before the first load
+	Defaults map[string]bool
+}
+
+// Client keeps an in-memory snapshot of the flag definitions, refreshed in the background,
+// and evaluates flags locally. When a refresh fails the last known flags are kept.
+type Client struct {
+	source Source
+	config Config
+
+	mu    sync.RWMutex
+	flags map[string]Flag
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewClient loads the flags and starts refreshing them, call Close to stop
+func NewClient(source Source, config Config) *Client {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DefaultRefreshInterval
+	}
+
+	c := &Client{
+		source: source,
+		config: config,
+		flags:  map[string]Flag{},
+		stop:   make(chan struct{}),
+	}
+	c.refresh()
+	go c.run()
+	return c
+}
+
+func (c *Client) run() {
+	ticker := time.NewTicker(c.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.refresh()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *Client) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.RefreshInterval)
+	defer cancel()
+
+	if err := c.Refresh(ctx); err != nil {
+		logger.Warnf("refresh feature flags", err)
+	}
+}
+
+// Refresh loads the flags from the source right away
+func (c *Client) Refresh(ctx context.Context) error {
+	list, err := c.source.Flags(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get flags")
+	}
+
+	flags := make(map[string]Flag, len(list))
+	for _, flag := range list {
+		flags[flag.Key] = flag
+	}
+
+	c.mu.Lock()
+	c.flags = flags
+	c.mu.Unlock()
+	return nil
+}
+
+// Flag returns the definition of key, ok is false if the source does not have it
+func (c *Client) Flag(key string) (flag Flag, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	flag, ok = c.flags[key]
+	return
+}
+
+// Evaluate evaluates key for target, falling back to the configured default
+func (c *Client) Evaluate(key string, target Target) bool {
+	flag, ok := c.Flag(key)
+	if !ok {
+		return c.config.Defaults[key]
+	}
+	return flag.Evaluate(target)
+}
+
+// IsEnabled evaluates key for the target of ctx, see WithTarget
+func (c *Client) IsEnabled(ctx context.Context, key string) bool {
+	return c.Evaluate(key, TargetFrom(ctx))
+}
+
+// Close stops the background refresh
+func (c *Client) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+type targetKey struct{}
+
+// WithTarget returns a copy of ctx holding target
+func WithTarget(ctx context.Context, target Target) context.Context {
+	return context.WithValue(ctx, targetKey{}, target)
+}
+
+// TargetFrom returns the target set by WithTarget, or under TargetContextKey of a gin context
+func TargetFrom(ctx context.Context) Target {
+	if target, ok := ctx.Value(targetKey{}).(Target); ok {
+		return target
+	}
+	target, _ := ctx.Value(TargetContextKey).(Target)
+	return target
+}
@@ -0,0 +1,146 @@
+package featureflag
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// flag statuses, StatusMaintenance flags evaluate as disabled
+const (
+	StatusEnabled     = "enabled"
+	StatusDisabled    = "disabled"
+	StatusMaintenance = "maintenance"
+)
+
+// Flag a feature flag definition. Rules are evaluated in order and the first matching rule wins,
+// Enabled applies when no rule matches.
+type Flag struct {
+	Key     string `json:"key"`
+	Status  string `json:"status,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Rules   []Rule `json:"rules,omitempty"`
+}
+
+// Rule enables the flag for targets matching every set condition.
+// With Percentage set only that share of matching targets is enabled, sticky per member or device,
+// targets with neither skip the rule.
+type Rule struct {
+	MemberIDs     []int    `json:"member_ids,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	MinAppVersion string   `json:"min_app_version,omitempty"`
+	MaxAppVersion string   `json:"max_app_version,omitempty"`
+	Percentage    *int     `json:"percentage,omitempty"`
+	Enabled       bool     `json:"enabled"`
+}
+
+// Target the subject a flag is evaluated for
+type Target struct {
+	MemberID   int
+	Roles      []string
+	AppVersion string
+	// DeviceID keeps percentage rollouts sticky for guests
+	DeviceID string
+}
+
+// Evaluate evaluates the flag for target
+func (f Flag) Evaluate(target Target) bool {
+	if f.Status == StatusDisabled || f.Status == StatusMaintenance {
+		return false
+	}
+
+	for _, rule := range f.Rules {
+		if !rule.matches(target) {
+			continue
+		}
+		if rule.Percentage != nil {
+			n, ok := bucket(f.Key, target)
+			if !ok {
+				// without a member or device id the target is not part of the rollout
+				continue
+			}
+			return n < *rule.Percentage
+		}
+		return rule.Enabled
+	}
+	return f.Enabled
+}
+
+func (r Rule) matches(target Target) bool {
+	if len(r.MemberIDs) > 0 && !containsInt(r.MemberIDs, target.MemberID) {
+		return false
+	}
+	if len(r.Roles) > 0 && !containsAny(r.Roles, target.Roles) {
+		return false
+	}
+	if r.MinAppVersion != "" && compareVersion(target.AppVersion, r.MinAppVersion) < 0 {
+		return false
+	}
+	if r.MaxAppVersion != "" && compareVersion(target.AppVersion, r.MaxAppVersion) > 0 {
+		return false
+	}
+	return true
+}
+
+// bucket hashes the flag key with the member id, or the device id for guests, into [0, 100).
+// ok is false when target has neither.
+func bucket(key string, target Target) (n int, ok bool) {
+	id := target.DeviceID
+	if target.MemberID > 0 {
+		id = strconv.Itoa(target.MemberID)
+	}
+	if id == "" {
+		return 0, false
+	}
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%v:%v", key, id)
+	return int(h.Sum32() % 100), true
+}
+
+// compareVersion compares dot separated numeric versions, e.g. 1.10.0 > 1.9, an empty version is the lowest
+func compareVersion(a, b string) int {
+	if a == "" || b == "" {
+		return strings.Compare(a, b)
+	}
+
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values, targets []string) bool {
+	for _, v := range values {
+		for _, t := range targets {
+			if v == t {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package featureflag_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/featureflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func percentage(p int) *int {
+	return &p
+}
+
+func TestEvaluate(t *testing.T) {
+	flag := featureflag.Flag{
+		Key: "chat",
+		Rules: []featureflag.Rule{
+			{MemberIDs: []int{7}, Enabled: false},
+			{Roles: []string{"admin"}, Enabled: true},
+			{MinAppVersion: "2.10.0", Percentage: percentage(100)},
+			{MaxAppVersion: "1.9", Percentage: percentage(0)},
+		},
+		Enabled: true,
+	}
+
+	tests := []struct {
+		name   string
+		target featureflag.Target
+		want   bool
+	}{
+		{name: "member excluded", target: featureflag.Target{MemberID: 7, Roles: []string{"admin"}}, want: false},
+		{name: "role", target: featureflag.Target{MemberID: 8, Roles: []string{"admin"}}, want: true},
+		{name: "app version above minimum", target: featureflag.Target{AppVersion: "2.10.1", DeviceID: "device-1"}, want: true},
+		{name: "app version below maximum", target: featureflag.Target{AppVersion: "1.8.5", DeviceID: "device-1"}, want: false},
+		{name: "default", target: featureflag.Target{AppVersion: "2.9"}, want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, flag.Evaluate(test.target))
+		})
+	}
+
+	flag.Status = featureflag.StatusMaintenance
+	assert.False(t, flag.Evaluate(featureflag.Target{Roles: []string{"admin"}}))
+}
+
+func TestEvaluatePercentage(t *testing.T) {
+	flag := featureflag.Flag{
+		Key:   "chat",
+		Rules: []featureflag.Rule{{Percentage: percentage(30)}},
+	}
+
+	enabled := 0
+	for id := 1; id <= 10000; id++ {
+		target := featureflag.Target{MemberID: id}
+		result := flag.Evaluate(target)
+		assert.Equal(t, result, flag.Evaluate(target), "sticky per member")
+		if result {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 3000, enabled, 300)
+}
+
+func TestEvaluatePercentageWithoutID(t *testing.T) {
+	flag := featureflag.Flag{
+		Key:     "chat",
+		Enabled: true,
+		Rules:   []featureflag.Rule{{Percentage: percentage(0)}},
+	}
+
+	assert.True(t, flag.Evaluate(featureflag.Target{}), "anonymous targets fall through to Enabled")
+	assert.False(t, flag.Evaluate(featureflag.Target{DeviceID: "device-1"}))
+}
+
+func TestHTTPSourceContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		_, err := featureflag.NewHTTPSource(server.URL).Flags(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Flags ignored the context deadline")
+	}
+}
+
+type source struct {
+	flags []featureflag.Flag
+	err   error
+}
+
+func (s *source) Flags(context.Context) ([]featureflag.Flag, error) {
+	return s.flags, s.err
+}
+
+func TestClient(t *testing.T) {
+	src := &source{err: errors.New("connection refused")}
+	client := featureflag.NewClient(src, featureflag.Config{Defaults: map[string]bool{"chat": true}})
+	defer client.Close()
+
+	ctx := featureflag.WithTarget(context.Background(), featureflag.Target{MemberID: 1})
+	assert.True(t, client.IsEnabled(ctx, "chat"), "default before the first load")
+	assert.False(t, client.IsEnabled(ctx, "unknown"))
+
+	src.flags, src.err = []featureflag.Flag{{Key: "chat", Status: featureflag.StatusDisabled}}, nil
+	assert.Nil(t, client.Refresh(ctx))
+	assert.False(t, client.IsEnabled(ctx, "chat"))
+
+	src.err = errors.New("connection refused")
+	assert.NotNil(t, client.Refresh(ctx))
+	assert.False(t, client.IsEnabled(ctx, "chat"), "last known flags are kept")
+}
@@ -1,9 +1,12 @@
 package jwt
 
 import (
+	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
 )
 
 type UserClaims struct {
@@ -18,13 +21,64 @@ type UserClaims struct {
 	Language    string    `json:"language"`
 }
 
+// RegisteredClaims the registered claims of jwt.RegisteredClaims with the jti as Id,
+// so the id claim of UserClaims stays reachable as AccessClaims.ID.
+// It replaces the embedded jwt-go StandardClaims: ExpiresAt, IssuedAt and NotBefore are
+// *jwt.NumericDate instead of int64 unix seconds and Audience is a jwt.ClaimStrings.
+type RegisteredClaims struct {
+	Audience  jwt.ClaimStrings `json:"aud,omitempty"`
+	ExpiresAt *jwt.NumericDate `json:"exp,omitempty"`
+	Id        string           `json:"jti,omitempty"`
+	IssuedAt  *jwt.NumericDate `json:"iat,omitempty"`
+	Issuer    string           `json:"iss,omitempty"`
+	NotBefore *jwt.NumericDate `json:"nbf,omitempty"`
+	Subject   string           `json:"sub,omitempty"`
+}
+
+func (c RegisteredClaims) GetExpirationTime() (*jwt.NumericDate, error) { return c.ExpiresAt, nil }
+func (c RegisteredClaims) GetIssuedAt() (*jwt.NumericDate, error)       { return c.IssuedAt, nil }
+func (c RegisteredClaims) GetNotBefore() (*jwt.NumericDate, error)      { return c.NotBefore, nil }
+func (c RegisteredClaims) GetIssuer() (string, error)                   { return c.Issuer, nil }
+func (c RegisteredClaims) GetSubject() (string, error)                  { return c.Subject, nil }
+func (c RegisteredClaims) GetAudience() (jwt.ClaimStrings, error)       { return c.Audience, nil }
+
 type RefreshClaims struct {
-	jwt.StandardClaims
+	RegisteredClaims
 	Type string `json:"type"`
 }
 
 type AccessClaims struct {
-	jwt.StandardClaims
+	RegisteredClaims
 	Type string `json:"type"`
+	// Roles granted in addition to UserClaims.RoleID
+	Roles []string `json:"roles,omitempty"`
+	// Scope space separated scopes granted to the token
+	Scope string `json:"scope,omitempty"`
 	UserClaims
 }
+
+// AllRoles returns RoleID followed by Roles
+func (c *AccessClaims) AllRoles() []string {
+	if c.RoleID == "" {
+		return c.Roles
+	}
+	return append([]string{c.RoleID}, c.Roles...)
+}
+
+// Scopes returns the scopes of the scope claim
+func (c *AccessClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// MemberID decrypts the member id from the id claim
+func (c *AccessClaims) MemberID() (int, error) {
+	if c.ID == "" {
+		return -1, errors.Wrap(ErrMalformed, "missing id claim")
+	}
+
+	id := aes.Decrypt(c.ID)
+	if id == -1 {
+		return -1, errors.Wrap(ErrMalformed, "invalid id claim")
+	}
+	return id, nil
+}
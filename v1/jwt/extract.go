@@ -1,74 +1,108 @@
 package jwt
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
-	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/pkg/errors"
 )
 
-// extractClaims extracts claims from JWT, returns claims as map
-func extractClaims(tokenStr string, skipClaimsValidation ...bool) (jwt.MapClaims, error) {
-	hmacSecretString := os.Getenv("JWT_ACCESS_SIGNATURE_KEY")
-	hmacSecret := []byte(hmacSecretString)
-	parser := new(jwt.Parser)
-	parser.SkipClaimsValidation = skipClaimsValidation[0]
-	token, err := parser.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// check token signing method etc
-		return hmacSecret, nil
-	})
-
+// extractAccessClaims parses and validates a token that is not a refresh token
+func extractAccessClaims(tokenStr string, opts Options) (*AccessClaims, error) {
+	claims := AccessClaims{}
+	_, err := opts.parser().ParseWithClaims(tokenStr, &claims, keyFunc(accessKey()))
 	if err != nil {
-		return nil, err
+		return nil, parseError(err)
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		err = errors.New("invalid JWT Token")
+	if err = opts.checkType(claims.Type); err != nil {
 		return nil, err
 	}
+	if opts.Type == "" && claims.Type == TokenTypeRefresh {
+		return nil, ErrInvalidTokenType
+	}
 
-	return claims, nil
+	if !opts.SkipRevocationCheck {
+		err = checkRevoked(claims.Id, claims.ID, claims.IssuedAt, opts.failOpen())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &claims, nil
 }
 
-// ExtractID extracts only the id from JWT
+// skipOptions returns the options of the skipClaimsValidation flag, claims are validated unless true.
+// Revocation is not checked, use the WithOptions variants to check it.
+func skipOptions(skipClaimsValidation []bool) Options {
+	return Options{
+		SkipClaimsValidation: len(skipClaimsValidation) > 0 && skipClaimsValidation[0],
+		SkipRevocationCheck:  true,
+	}
+}
+
+// ExtractID extracts only the id from JWT without checking revocation
 func ExtractID(ah string, skipClaimsValidation ...bool) (int, error) {
+	return ExtractIDWithOptions(ah, skipOptions(skipClaimsValidation))
+}
+
+// ExtractIDWithOptions extracts only the id from JWT validated against opts
+func ExtractIDWithOptions(ah string, opts Options) (int, error) {
 	ts := strings.Replace(ah, "Bearer ", "", -1)
-	claimsMap, err := extractClaims(ts, append(skipClaimsValidation, true)[0])
+	claims, err := extractAccessClaims(ts, opts)
 	if err != nil {
 		return -1, errors.Wrap(err, "extract claims")
 	}
 
-	id := aes.Decrypt(claimsMap["id"].(string))
-	if id == -1 {
-		return -1, fmt.Errorf("invalid ID")
-	}
-
-	return id, nil
+	return claims.MemberID()
 }
 
-// ExtractClient extracts only the id from JWT
+// ExtractClient extracts the access claims from JWT without checking revocation
 func ExtractClient(ah string, skipClaimsValidation ...bool) (*AccessClaims, error) {
+	return ExtractClientWithOptions(ah, skipOptions(skipClaimsValidation))
+}
+
+// ExtractClientWithOptions extracts the access claims from JWT validated against opts
+func ExtractClientWithOptions(ah string, opts Options) (*AccessClaims, error) {
 	ts := strings.Replace(ah, "Bearer ", "", -1)
-	claims := AccessClaims{}
-	claimsMap, err := extractClaims(ts, append(skipClaimsValidation, true)[0])
+	claims, err := extractAccessClaims(ts, opts)
 	if err != nil {
 		return nil, errors.Wrap(err, "extract claims")
 	}
 
-	j, err := json.Marshal(&claimsMap)
+	return claims, nil
+}
+
+// ExtractRefresh extracts and validates a refresh token issued by IssueRefresh
+func ExtractRefresh(ah string) (*RefreshClaims, error) {
+	return ExtractRefreshWithOptions(ah, Options{})
+}
+
+// ExtractRefreshWithOptions extracts a refresh token validated against opts, opts.Type is ignored
+func ExtractRefreshWithOptions(ah string, opts Options) (*RefreshClaims, error) {
+	ts := strings.Replace(ah, "Bearer ", "", -1)
+	options := opts
+	options.Type = TokenTypeRefresh
+
+	claims := RefreshClaims{}
+	_, err := options.parser().ParseWithClaims(ts, &claims, func(token *jwt.Token) (interface{}, error) {
+		// refresh tokens are only ever issued with the HMAC refresh key
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Wrap(ErrAlgorithmNotAllowed, token.Method.Alg())
+		}
+		return refreshKey(), nil
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "marshal")
+		return nil, errors.Wrap(parseError(err), "extract claims")
 	}
 
-	err = json.Unmarshal(j, &claims)
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshal")
+	if err = options.checkType(claims.Type); err != nil {
+		return nil, err
 	}
 
+	if !options.SkipRevocationCheck {
+		if err = checkRevoked(claims.Id, claims.Subject, claims.IssuedAt, options.failOpen()); err != nil {
+			return nil, err
+		}
+	}
 	return &claims, nil
 }
@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/forkyid/go-utils/v1/util/env"
+	"github.com/forkyid/go-utils/v1/uuid"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// token types, set in the type claim
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrNoSignatureKey   = errors.New("signature key not configured")
+	ErrInvalidTokenType = errors.New("invalid token type")
+	ErrInvalidSubject   = errors.New("invalid subject")
+)
+
+// issuer returns JWT_ISSUER, AppName by default
+func issuer() string {
+	return env.GetStr("JWT_ISSUER", AppName)
+}
+
+// accessKey returns JWT_ACCESS_SIGNATURE_KEY
+func accessKey() []byte {
+	return []byte(os.Getenv("JWT_ACCESS_SIGNATURE_KEY"))
+}
+
+// refreshKey returns JWT_REFRESH_SIGNATURE_KEY, JWT_ACCESS_SIGNATURE_KEY by default
+func refreshKey() []byte {
+	return []byte(env.GetStr("JWT_REFRESH_SIGNATURE_KEY", os.Getenv("JWT_ACCESS_SIGNATURE_KEY")))
+}
+
+func registeredClaims(subject string, duration time.Duration) RegisteredClaims {
+	now := time.Now()
+	return RegisteredClaims{
+		Id:        uuid.GetUUID(),
+		Issuer:    issuer(),
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+	}
+}
+
+func sign(claims jwt.Claims, key []byte) (string, error) {
+	if len(key) == 0 {
+		return "", ErrNoSignatureKey
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	return token, errors.Wrap(err, "sign")
+}
+
+// encryptSubject encrypts the plain member id subject with aes
+func encryptSubject(subject string) (string, error) {
+	id, err := strconv.Atoi(subject)
+	if err != nil || id <= 0 {
+		return "", errors.Wrap(ErrInvalidSubject, subject)
+	}
+	return aes.Encrypt(id), nil
+}
+
+// IssueAccess issues an access token for user valid for AccessTokenDurationMinute.
+// user.ID is the plain member id, it is encrypted with aes and set as both the id and sub claims.
+func IssueAccess(user UserClaims) (string, error) {
+	id, err := encryptSubject(user.ID)
+	if err != nil {
+		return "", err
+	}
+	user.ID = id
+	claims := AccessClaims{
+		RegisteredClaims: registeredClaims(id, AccessTokenDurationMinute*time.Minute),
+		Type:             TokenTypeAccess,
+		UserClaims:       user,
+	}
+	return sign(claims, accessKey())
+}
+
+// IssueRefresh issues a refresh token valid for RefreshTokenDurationHour.
+// subject is the plain member id, it is encrypted with aes and set as the sub claim.
+func IssueRefresh(subject string) (string, error) {
+	id, err := encryptSubject(subject)
+	if err != nil {
+		return "", err
+	}
+	claims := RefreshClaims{
+		RegisteredClaims: registeredClaims(id, RefreshTokenDurationHour*time.Hour),
+		Type:             TokenTypeRefresh,
+	}
+	return sign(claims, refreshKey())
+}
@@ -0,0 +1,188 @@
+package jwt_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/forkyid/go-utils/v1/cache/cachetest"
+	"github.com/forkyid/go-utils/v1/jwt"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("AES_KEY", "test-salt")
+	os.Setenv("AES_MIN_LENGTH", "8")
+	os.Setenv("JWT_ACCESS_SIGNATURE_KEY", "access-secret")
+	os.Setenv("JWT_REFRESH_SIGNATURE_KEY", "refresh-secret")
+	if _, err := cachetest.Start(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestIssueAccess(t *testing.T) {
+	token, err := jwt.IssueAccess(jwt.UserClaims{ID: "42", Username: "forky"})
+	assert.Nil(t, err)
+
+	id, err := jwt.ExtractID("Bearer " + token)
+	assert.Nil(t, err)
+	assert.Equal(t, 42, id)
+
+	claims, err := jwt.ExtractClient("Bearer " + token)
+	assert.Nil(t, err)
+	assert.Equal(t, "forky", claims.Username)
+	assert.Equal(t, jwt.TokenTypeAccess, claims.Type)
+	assert.NotEmpty(t, claims.Id)
+	assert.Equal(t, claims.ID, claims.Subject)
+
+	_, err = jwt.ExtractRefresh(token)
+	assert.NotNil(t, err)
+}
+
+func TestIssueRefresh(t *testing.T) {
+	token, err := jwt.IssueRefresh("42")
+	assert.Nil(t, err)
+
+	claims, err := jwt.ExtractRefresh(token)
+	assert.Nil(t, err)
+	assert.Equal(t, jwt.TokenTypeRefresh, claims.Type)
+
+	_, err = jwt.ExtractID("Bearer " + token)
+	assert.NotNil(t, err)
+}
+
+func TestIssueInvalidSubject(t *testing.T) {
+	for _, subject := range []string{"", "forky", "0"} {
+		_, err := jwt.IssueAccess(jwt.UserClaims{ID: subject})
+		assert.ErrorIs(t, err, jwt.ErrInvalidSubject)
+		_, err = jwt.IssueRefresh(subject)
+		assert.ErrorIs(t, err, jwt.ErrInvalidSubject)
+	}
+}
+
+func TestRefreshAsAccess(t *testing.T) {
+	t.Setenv("JWT_REFRESH_SIGNATURE_KEY", "access-secret")
+	token, err := jwt.IssueRefresh("42")
+	assert.Nil(t, err)
+
+	_, err = jwt.ExtractID("Bearer " + token)
+	assert.ErrorIs(t, err, jwt.ErrInvalidTokenType)
+}
+
+func signAccess(t *testing.T, claims gojwt.MapClaims) string {
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte("access-secret"))
+	assert.Nil(t, err)
+	return "Bearer " + token
+}
+
+func TestExtractOptions(t *testing.T) {
+	now := time.Now()
+	exp := now.Add(time.Minute).Unix()
+	id := aes.Encrypt(42)
+
+	tests := []struct {
+		name    string
+		token   string
+		options jwt.Options
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: signAccess(t, gojwt.MapClaims{"id": id, "exp": now.Add(time.Minute).Unix()}),
+		},
+		{
+			name:    "expired",
+			token:   signAccess(t, gojwt.MapClaims{"id": id, "exp": now.Add(-time.Minute).Unix()}),
+			wantErr: jwt.ErrExpired,
+		},
+		{
+			name:    "expired within leeway",
+			token:   signAccess(t, gojwt.MapClaims{"id": id, "exp": now.Add(-time.Minute).Unix()}),
+			options: jwt.Options{Leeway: 2 * time.Minute},
+		},
+		{
+			name:    "expired skipping validation",
+			token:   signAccess(t, gojwt.MapClaims{"id": id, "exp": now.Add(-time.Minute).Unix()}),
+			options: jwt.Options{SkipClaimsValidation: true},
+		},
+		{
+			name:    "missing exp",
+			token:   signAccess(t, gojwt.MapClaims{"id": id}),
+			wantErr: jwt.ErrInvalidClaims,
+		},
+		{
+			name:    "missing exp skipping validation",
+			token:   signAccess(t, gojwt.MapClaims{"id": id}),
+			options: jwt.Options{SkipClaimsValidation: true},
+		},
+		{
+			name:    "missing id",
+			token:   signAccess(t, gojwt.MapClaims{"exp": now.Add(time.Minute).Unix()}),
+			wantErr: jwt.ErrMalformed,
+		},
+		{
+			name:    "malformed",
+			token:   "Bearer not-a-token",
+			wantErr: jwt.ErrMalformed,
+		},
+		{
+			name:    "audience",
+			token:   signAccess(t, gojwt.MapClaims{"id": id, "aud": []string{"web", "app"}, "exp": exp}),
+			options: jwt.Options{Audience: "app"},
+		},
+		{
+			name:    "audience mismatch",
+			token:   signAccess(t, gojwt.MapClaims{"id": id, "aud": "web", "exp": exp}),
+			options: jwt.Options{Audience: "app"},
+			wantErr: jwt.ErrInvalidClaims,
+		},
+		{
+			name:    "issuer mismatch",
+			token:   signAccess(t, gojwt.MapClaims{"id": id, "iss": "other", "exp": exp}),
+			options: jwt.Options{Issuer: "forky"},
+			wantErr: jwt.ErrInvalidClaims,
+		},
+		{
+			name:    "type mismatch",
+			token:   signAccess(t, gojwt.MapClaims{"id": id, "type": "service", "exp": exp}),
+			options: jwt.Options{Type: jwt.TokenTypeAccess},
+			wantErr: jwt.ErrInvalidTokenType,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := jwt.ExtractIDWithOptions(test.token, test.options)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, 42, id)
+		})
+	}
+}
+
+func TestExtractSkipClaimsValidation(t *testing.T) {
+	expired := signAccess(t, gojwt.MapClaims{"id": aes.Encrypt(42), "exp": time.Now().Add(-time.Minute).Unix()})
+
+	_, err := jwt.ExtractID(expired)
+	assert.ErrorIs(t, err, jwt.ErrExpired)
+
+	id, err := jwt.ExtractID(expired, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 42, id)
+
+	_, err = jwt.ExtractClient(expired, false)
+	assert.ErrorIs(t, err, jwt.ErrExpired)
+}
+
+func TestExtractSignature(t *testing.T) {
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, gojwt.MapClaims{}).SignedString([]byte("other-secret"))
+	assert.Nil(t, err)
+
+	_, err = jwt.ExtractClient(token)
+	assert.ErrorIs(t, err, jwt.ErrSignature)
+}
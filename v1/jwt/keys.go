@@ -0,0 +1,395 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/util/env"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultAllowedAlgorithms   = "HS256"
+	DefaultJWKSRefreshInterval = 5 * time.Minute
+
+	// jwksMinRefreshInterval limits refreshes triggered by unknown kids
+	jwksMinRefreshInterval = 10 * time.Second
+)
+
+var (
+	ErrAlgorithmNotAllowed = errors.New("signing algorithm not allowed")
+	ErrKeyNotFound         = errors.New("verification key not found")
+)
+
+// allowedAlgorithms returns JWT_ALLOWED_ALGORITHMS, comma separated, DefaultAllowedAlgorithms by default
+func allowedAlgorithms() map[string]bool {
+	allowed := map[string]bool{}
+	for _, alg := range strings.Split(env.GetStr("JWT_ALLOWED_ALGORITHMS", DefaultAllowedAlgorithms), ",") {
+		if alg = strings.TrimSpace(alg); alg != "" {
+			allowed[alg] = true
+		}
+	}
+	return allowed
+}
+
+// keyFunc returns a jwt.Keyfunc accepting only allowed algorithms.
+// HMAC tokens are verified with hmacSecret, asymmetric tokens with the JWKS key matching kid
+// when a JWKS is configured, with the PEM public key otherwise.
+func keyFunc(hmacSecret []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		alg := token.Method.Alg()
+		if !allowedAlgorithms()[alg] {
+			return nil, errors.Wrap(ErrAlgorithmNotAllowed, alg)
+		}
+
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			if len(hmacSecret) == 0 {
+				return nil, ErrNoSignatureKey
+			}
+			return hmacSecret, nil
+		}
+
+		var key interface{}
+		var err error
+		if set := defaultJWKS(); set != nil {
+			kid, _ := token.Header["kid"].(string)
+			key, err = set.Key(kid)
+		} else {
+			key, err = accessPublicKey()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, ErrKeyNotFound
+		}
+
+		// the key type must match the signing method, e.g. no RSA key for an ES256 token
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			_, ok := key.(*rsa.PublicKey)
+			if !ok {
+				return nil, jwt.ErrInvalidKeyType
+			}
+		case *jwt.SigningMethodECDSA:
+			_, ok := key.(*ecdsa.PublicKey)
+			if !ok {
+				return nil, jwt.ErrInvalidKeyType
+			}
+		case *jwt.SigningMethodEd25519:
+			_, ok := key.(ed25519.PublicKey)
+			if !ok {
+				return nil, jwt.ErrInvalidKeyType
+			}
+		default:
+			return nil, errors.Wrap(ErrAlgorithmNotAllowed, alg)
+		}
+		return key, nil
+	}
+}
+
+// ParsePublicKeyPEM parses a PKIX public key or certificate holding an RSA, ECDSA or Ed25519 key
+func ParsePublicKeyPEM(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse certificate")
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		return key, errors.Wrap(err, "parse pkcs1 public key")
+	default:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return key, errors.Wrap(err, "parse pkix public key")
+	}
+}
+
+var (
+	publicKeyOnce sync.Once
+	publicKey     interface{}
+	publicKeyErr  error
+)
+
+// accessPublicKey returns the key from JWT_ACCESS_PUBLIC_KEY (PEM) or JWT_ACCESS_PUBLIC_KEY_FILE, parsed once
+func accessPublicKey() (interface{}, error) {
+	publicKeyOnce.Do(func() {
+		data := []byte(env.GetStr("JWT_ACCESS_PUBLIC_KEY"))
+		if file := env.GetStr("JWT_ACCESS_PUBLIC_KEY_FILE"); len(data) == 0 && file != "" {
+			data, publicKeyErr = ioutil.ReadFile(file)
+			if publicKeyErr != nil {
+				return
+			}
+		}
+		if len(data) > 0 {
+			publicKey, publicKeyErr = ParsePublicKeyPEM(data)
+		}
+	})
+	return publicKey, publicKeyErr
+}
+
+// JWK a single JSON Web Key, only the public key members are read
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicKey returns the *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey of the jwk
+func (k JWK) PublicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode n")
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode e")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		curves := map[string]elliptic.Curve{
+			"P-256": elliptic.P256(),
+			"P-384": elliptic.P384(),
+			"P-521": elliptic.P521(),
+		}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %v", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode x")
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode y")
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %v", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode x")
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %v", k.Kty)
+}
+
+// JWKS a JSON Web Key Set read from an http(s) url or a local file, cached and refreshed on interval.
+// A kid missing from the cache triggers a refresh, at most once every 10 seconds.
+// Concurrent refreshes share a single fetch.
+type JWKS struct {
+	source          string
+	refreshInterval time.Duration
+	httpClient      *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+
+	refreshMu sync.Mutex
+	inflight  *jwksRefresh
+}
+
+// jwksRefresh a fetch in progress, err is set before done is closed
+type jwksRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKS creates a key set for source, an http(s) url or a file path
+func NewJWKS(source string, refreshInterval time.Duration) *JWKS {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultJWKSRefreshInterval
+	}
+	return &JWKS{
+		source:          source,
+		refreshInterval: refreshInterval,
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
+		keys:            map[string]interface{}{},
+	}
+}
+
+// Key returns the public key for kid
+func (s *JWKS) Key(kid string) (interface{}, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	age := time.Since(s.fetchedAt)
+	s.mu.RUnlock()
+
+	if (ok && age < s.refreshInterval) || (!ok && age < jwksMinRefreshInterval) {
+		if !ok {
+			return nil, errors.Wrap(ErrKeyNotFound, kid)
+		}
+		return key, nil
+	}
+
+	if err := s.Refresh(); err != nil {
+		if ok {
+			// keep serving the cached key while the source is unavailable
+			return key, nil
+		}
+		return nil, errors.Wrap(err, "refresh jwks")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok = s.keys[kid]; !ok {
+		return nil, errors.Wrap(ErrKeyNotFound, kid)
+	}
+	return key, nil
+}
+
+// Refresh reloads the key set from source, waiting for the refresh in progress if any
+func (s *JWKS) Refresh() error {
+	s.refreshMu.Lock()
+	if call := s.inflight; call != nil {
+		s.refreshMu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &jwksRefresh{done: make(chan struct{})}
+	s.inflight = call
+	s.refreshMu.Unlock()
+
+	call.err = s.refresh()
+
+	s.refreshMu.Lock()
+	s.inflight = nil
+	s.refreshMu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+func (s *JWKS) refresh() error {
+	data, err := s.read()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetchedAt = time.Now()
+	if err != nil {
+		return err
+	}
+
+	set := struct {
+		Keys []JWK `json:"keys"`
+	}{}
+	if err = json.Unmarshal(data, &set); err != nil {
+		return errors.Wrap(err, "unmarshal")
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	s.keys = keys
+	return nil
+}
+
+func (s *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		data, err := ioutil.ReadFile(s.source)
+		return data, errors.Wrap(err, "read file")
+	}
+
+	resp, err := s.httpClient.Get(s.source)
+	if err != nil {
+		return nil, errors.Wrap(err, "get")
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read body")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("[GET] %v: %v", s.source, resp.StatusCode)
+	}
+	return data, nil
+}
+
+var (
+	jwksOnce sync.Once
+	jwksMu   sync.RWMutex
+	jwks     *JWKS
+)
+
+// defaultJWKS returns the key set from JWT_JWKS_URL or JWT_JWKS_FILE, nil if neither is set.
+// JWT_JWKS_REFRESH_INTERVAL sets the refresh interval in seconds.
+func defaultJWKS() *JWKS {
+	jwksOnce.Do(func() {
+		source := env.GetStr("JWT_JWKS_URL", env.GetStr("JWT_JWKS_FILE"))
+		if source == "" {
+			return
+		}
+		interval := time.Duration(env.GetInt("JWT_JWKS_REFRESH_INTERVAL")) * time.Second
+		jwksMu.Lock()
+		jwks = NewJWKS(source, interval)
+		jwksMu.Unlock()
+	})
+
+	jwksMu.RLock()
+	defer jwksMu.RUnlock()
+	return jwks
+}
+
+// SetJWKS replaces the key set configured from environment, safe to call while tokens are extracted
+func SetJWKS(set *JWKS) {
+	jwksOnce.Do(func() {})
+	jwksMu.Lock()
+	jwks = set
+	jwksMu.Unlock()
+}
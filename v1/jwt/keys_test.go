@@ -0,0 +1,163 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/forkyid/go-utils/v1/jwt"
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func accessClaims(id int) jwt.AccessClaims {
+	return jwt.AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: gojwt.NewNumericDate(time.Now().Add(time.Minute))},
+		Type:             jwt.TokenTypeAccess,
+		UserClaims:       jwt.UserClaims{ID: aes.Encrypt(id)},
+	}
+}
+
+func signWithKid(t *testing.T, method gojwt.SigningMethod, kid string, key interface{}) string {
+	token := gojwt.NewWithClaims(method, accessClaims(42))
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return signed
+}
+
+func TestAsymmetricKeysFromJWKS(t *testing.T) {
+	t.Setenv("JWT_ALLOWED_ALGORITHMS", "HS256,RS256,EdDSA")
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	encode := base64.RawURLEncoding.EncodeToString
+	set, err := json.Marshal(map[string]interface{}{
+		"keys": []jwt.JWK{
+			{Kid: "ed", Kty: "OKP", Crv: "Ed25519", X: encode(edPublic)},
+			{
+				Kid: "rsa", Kty: "RSA",
+				N: encode(rsaPrivate.N.Bytes()),
+				E: encode(big.NewInt(int64(rsaPrivate.E)).Bytes()),
+			},
+		},
+	})
+	assert.Nil(t, err)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, ioutil.WriteFile(file, set, 0600))
+	jwt.SetJWKS(jwt.NewJWKS(file, time.Minute))
+	defer jwt.SetJWKS(nil)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "eddsa", token: signWithKid(t, gojwt.SigningMethodEdDSA, "ed", edPrivate)},
+		{name: "rs256", token: signWithKid(t, gojwt.SigningMethodRS256, "rsa", rsaPrivate)},
+		{name: "unknown kid", token: signWithKid(t, gojwt.SigningMethodEdDSA, "other", edPrivate), wantErr: true},
+		{name: "kid of another key type", token: signWithKid(t, gojwt.SigningMethodRS256, "ed", rsaPrivate), wantErr: true},
+		{name: "algorithm not allowed", token: signWithKid(t, gojwt.SigningMethodRS512, "rsa", rsaPrivate), wantErr: true},
+		{name: "none", token: signWithKid(t, gojwt.SigningMethodNone, "rsa", gojwt.UnsafeAllowNoneSignatureType), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := jwt.ExtractID("Bearer " + test.token)
+			if test.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, 42, id)
+		})
+	}
+}
+
+func TestJWKSConcurrentRefresh(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer server.Close()
+	set := jwt.NewJWKS(server.URL, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := set.Key("unknown")
+			assert.ErrorIs(t, err, jwt.ErrKeyNotFound)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestSetJWKSConcurrent(t *testing.T) {
+	t.Setenv("JWT_ALLOWED_ALGORITHMS", "EdDSA")
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	token := signWithKid(t, gojwt.SigningMethodEdDSA, "ed", private)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	defer jwt.SetJWKS(nil)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			jwt.SetJWKS(jwt.NewJWKS(file, time.Minute))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			jwt.ExtractIDWithOptions("Bearer "+token, jwt.Options{SkipRevocationCheck: true})
+		}
+	}()
+	wg.Wait()
+}
+
+func TestHMACAlgorithmNotAllowed(t *testing.T) {
+	t.Setenv("JWT_ALLOWED_ALGORITHMS", "RS256")
+	token, err := jwt.IssueAccess(jwt.UserClaims{ID: "42"})
+	assert.Nil(t, err)
+
+	_, err = jwt.ExtractID("Bearer " + token)
+	assert.ErrorIs(t, err, jwt.ErrAlgorithmNotAllowed)
+	assert.ErrorIs(t, err, jwt.ErrSignature)
+}
+
+func TestParsePublicKeyPEM(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(public)
+	assert.Nil(t, err)
+
+	key, err := jwt.ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.Nil(t, err)
+	assert.Equal(t, public, key)
+
+	_, err = jwt.ParsePublicKeyPEM([]byte("not a pem"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,101 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrExpired       = errors.New("token expired")
+	ErrMalformed     = errors.New("token malformed")
+	ErrSignature     = errors.New("token signature invalid")
+	ErrInvalidClaims = errors.New("token claims invalid")
+)
+
+// Options configures the validation of extracted tokens, the zero value requires exp and validates exp, nbf and iat
+type Options struct {
+	// SkipClaimsValidation only verifies the signature
+	SkipClaimsValidation bool
+	// Leeway tolerates clock skew when validating exp, nbf and iat
+	Leeway time.Duration
+	// Audience is required in the aud claim when set
+	Audience string
+	// Issuer is required as the iss claim when set
+	Issuer string
+	// Type is required as the type claim when set,
+	// access extraction rejects refresh tokens when empty
+	Type string
+	// SkipRevocationCheck skips the cache lookup of tokens revoked by Revoke and RevokeAllForUser
+	SkipRevocationCheck bool
+	// RevocationFailOpen accepts tokens when the revocation cache is unavailable instead of
+	// returning ErrRevocationUnavailable, JWT_REVOCATION_FAIL_OPEN sets it for every extraction
+	RevocationFailOpen bool
+}
+
+// failOpen reports whether tokens are accepted when the revocation cache is unavailable
+func (o Options) failOpen() bool {
+	return o.RevocationFailOpen || revocationFailOpen()
+}
+
+// parser returns a parser validating registered claims against o
+func (o Options) parser() *jwt.Parser {
+	parserOptions := []jwt.ParserOption{jwt.WithIssuedAt(), jwt.WithExpirationRequired()}
+	if o.SkipClaimsValidation {
+		parserOptions = append(parserOptions, jwt.WithoutClaimsValidation())
+	}
+	if o.Leeway > 0 {
+		parserOptions = append(parserOptions, jwt.WithLeeway(o.Leeway))
+	}
+	if o.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(o.Audience))
+	}
+	if o.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(o.Issuer))
+	}
+	return jwt.NewParser(parserOptions...)
+}
+
+// checkType checks claimType against the required type
+func (o Options) checkType(claimType string) error {
+	if o.Type != "" && claimType != o.Type {
+		return ErrInvalidTokenType
+	}
+	return nil
+}
+
+// tokenError is matched by errors.Is against both kind and cause
+type tokenError struct {
+	kind  error
+	cause error
+}
+
+func (e *tokenError) Error() string {
+	if e.cause == nil {
+		return e.kind.Error()
+	}
+	return fmt.Sprintf("%v: %v", e.kind, e.cause)
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.kind
+}
+
+func (e *tokenError) Is(target error) bool {
+	return e.cause != nil && errors.Is(e.cause, target)
+}
+
+// parseError converts parser errors to ErrMalformed, ErrSignature, ErrExpired or ErrInvalidClaims
+func parseError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return &tokenError{kind: ErrMalformed, cause: err}
+	case errors.Is(err, jwt.ErrTokenUnverifiable), errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return &tokenError{kind: ErrSignature, cause: err}
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return &tokenError{kind: ErrExpired, cause: err}
+	}
+	return &tokenError{kind: ErrInvalidClaims, cause: err}
+}
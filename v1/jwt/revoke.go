@@ -0,0 +1,103 @@
+package jwt
+
+import (
+	"time"
+
+	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/forkyid/go-utils/v1/cache"
+	"github.com/forkyid/go-utils/v1/logger"
+	"github.com/forkyid/go-utils/v1/util/env"
+	"github.com/go-redis/redis"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrRevoked               = errors.New("token revoked")
+	ErrRevocationUnavailable = errors.New("token revocation check unavailable")
+)
+
+// RevokedTokenKey caches a revoked jti until the token expires
+type RevokedTokenKey struct {
+	ID string `cache:"key"`
+}
+
+// RevokedUserKey caches the time before which all tokens of a member are revoked
+type RevokedUserKey struct {
+	ID string `cache:"key"`
+}
+
+// revocationTTL outlives every token issued by IssueAccess and IssueRefresh
+const revocationTTL = RefreshTokenDurationHour * time.Hour
+
+func revokedTokenKey(jti string) string {
+	return cache.ExternalKey("global", RevokedTokenKey{ID: jti})
+}
+
+func revokedUserKey(encryptedID string) string {
+	return cache.ExternalKey("global", RevokedUserKey{ID: encryptedID})
+}
+
+// Revoke revokes the token with jti until expiresAt, e.g. on logout
+func Revoke(jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return errors.Wrap(ErrMalformed, "missing jti claim")
+	}
+
+	ttl := int(time.Until(expiresAt).Seconds()) + 1
+	if ttl <= 1 {
+		return nil
+	}
+	return errors.Wrap(cache.SetJSON(revokedTokenKey(jti), true, ttl), "set revoked token")
+}
+
+// RevokeAllForUser revokes every token of member id issued before the current second,
+// tokens issued within the same second as the call are still accepted
+func RevokeAllForUser(id int) error {
+	err := cache.SetJSON(revokedUserKey(aes.Encrypt(id)), time.Now().Unix(), int(revocationTTL.Seconds()))
+	return errors.Wrap(err, "set revoked user")
+}
+
+// revocationFailOpen returns JWT_REVOCATION_FAIL_OPEN, false by default
+func revocationFailOpen() bool {
+	return env.GetBool("JWT_REVOCATION_FAIL_OPEN", false)
+}
+
+// checkRevoked returns ErrRevoked if jti is revoked or the token of the member encryptedID was issued
+// before the second of its RevokeAllForUser call. When the cache is unavailable it returns ErrRevocationUnavailable,
+// or accepts the token if failOpen.
+func checkRevoked(jti, encryptedID string, issuedAt *jwt.NumericDate, failOpen bool) error {
+	unavailable := func(msg string, err error) error {
+		if failOpen {
+			logger.Warnf("redis: "+msg, err)
+			return nil
+		}
+		return &tokenError{kind: ErrRevocationUnavailable, cause: errors.Wrap(err, msg)}
+	}
+
+	if jti != "" {
+		revoked, err := cache.IsCacheExists(revokedTokenKey(jti))
+		if err != nil {
+			return unavailable("check revoked token", err)
+		}
+		if revoked {
+			return ErrRevoked
+		}
+	}
+
+	if encryptedID == "" {
+		return nil
+	}
+	var revokedAt int64
+	err := cache.GetUnmarshal(revokedUserKey(encryptedID), &revokedAt)
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return unavailable("check revoked user", err)
+	}
+	if issuedAt == nil || issuedAt.Unix() < revokedAt {
+		return ErrRevoked
+	}
+	return nil
+}
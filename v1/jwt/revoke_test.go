@@ -0,0 +1,76 @@
+package jwt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/forkyid/go-utils/v1/cache"
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevoke(t *testing.T) {
+	token, err := jwt.IssueAccess(jwt.UserClaims{ID: "42"})
+	assert.Nil(t, err)
+	other, err := jwt.IssueAccess(jwt.UserClaims{ID: "42"})
+	assert.Nil(t, err)
+
+	claims, err := jwt.ExtractClient(token)
+	assert.Nil(t, err)
+	assert.Nil(t, jwt.Revoke(claims.Id, claims.ExpiresAt.Time))
+
+	_, err = jwt.ExtractIDWithOptions(token, jwt.Options{})
+	assert.ErrorIs(t, err, jwt.ErrRevoked)
+	_, err = jwt.ExtractIDWithOptions(token, jwt.Options{SkipRevocationCheck: true})
+	assert.Nil(t, err)
+	_, err = jwt.ExtractID(token)
+	assert.Nil(t, err, "legacy extraction does not check revocation")
+	_, err = jwt.ExtractIDWithOptions(other, jwt.Options{})
+	assert.Nil(t, err)
+}
+
+func TestRevokeAllForUser(t *testing.T) {
+	access, err := jwt.IssueAccess(jwt.UserClaims{ID: "7"})
+	assert.Nil(t, err)
+	refresh, err := jwt.IssueRefresh("7")
+	assert.Nil(t, err)
+	otherUser, err := jwt.IssueAccess(jwt.UserClaims{ID: "8"})
+	assert.Nil(t, err)
+
+	// revocation applies to tokens issued in earlier seconds
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	assert.Nil(t, jwt.RevokeAllForUser(7))
+
+	_, err = jwt.ExtractIDWithOptions(access, jwt.Options{})
+	assert.ErrorIs(t, err, jwt.ErrRevoked)
+	_, err = jwt.ExtractRefresh(refresh)
+	assert.ErrorIs(t, err, jwt.ErrRevoked)
+	_, err = jwt.ExtractIDWithOptions(otherUser, jwt.Options{})
+	assert.Nil(t, err)
+
+	// tokens issued within the revocation second are accepted
+	access, err = jwt.IssueAccess(jwt.UserClaims{ID: "7"})
+	assert.Nil(t, err)
+	_, err = jwt.ExtractIDWithOptions(access, jwt.Options{})
+	assert.Nil(t, err)
+}
+
+func TestRevocationUnavailable(t *testing.T) {
+	token, err := jwt.IssueAccess(jwt.UserClaims{ID: "9"})
+	assert.Nil(t, err)
+
+	// an unreadable revocation entry can not be checked
+	key := cache.ExternalKey("global", jwt.RevokedUserKey{ID: aes.Encrypt(9)})
+	assert.Nil(t, cache.SetJSON(key, "not a time", 60))
+	defer cache.Delete(key)
+
+	_, err = jwt.ExtractIDWithOptions(token, jwt.Options{})
+	assert.ErrorIs(t, err, jwt.ErrRevocationUnavailable)
+	_, err = jwt.ExtractIDWithOptions(token, jwt.Options{RevocationFailOpen: true})
+	assert.Nil(t, err)
+
+	t.Setenv("JWT_REVOCATION_FAIL_OPEN", "true")
+	_, err = jwt.ExtractIDWithOptions(token, jwt.Options{})
+	assert.Nil(t, err)
+}
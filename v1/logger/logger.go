@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"errors"
 	"html/template"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/forkyid/go-utils/v1/uuid"
 	"github.com/gin-gonic/gin"
@@ -17,8 +19,9 @@ import (
 )
 
 var (
-	logger *logrus.Logger
-	cidrs  []*net.IPNet
+	logger       *logrus.Logger
+	accessLogger *logrus.Logger
+	cidrs        []*net.IPNet
 
 	serverEnvironments = map[string]bool{
 		"production":  true,
@@ -32,6 +35,9 @@ const (
 	tagLogIgnore = "logignore"
 )
 
+// RequestIDKey gin context key of the request id logged as Key
+const RequestIDKey = "response_id"
+
 func init() {
 	if logger == nil {
 		logger = logrus.New()
@@ -49,6 +55,13 @@ func init() {
 			})
 		}
 	}
+	if accessLogger == nil {
+		// access logs are written on info level in every environment
+		accessLogger = logrus.New()
+		accessLogger.SetLevel(logrus.InfoLevel)
+		accessLogger.SetFormatter(logger.Formatter)
+		accessLogger.SetOutput(logger.Out)
+	}
 	maxCidrBlocks := []string{
 		"127.0.0.1/8",    // localhost
 		"10.0.0.0/8",     // 24-bit block
@@ -183,7 +196,7 @@ func defineFields(ctx *gin.Context, args ...interface{}) (fields logrus.Fields)
 		return
 	}
 
-	getRequestID, ok := ctx.Get("response_id")
+	getRequestID, ok := ctx.Get(RequestIDKey)
 	if !ok || getRequestID == "" {
 		getRequestID = uuid.GetUUID()
 	} else {
@@ -217,6 +230,37 @@ func Errorf(ctx *gin.Context, errMsg string, err error, args ...interface{}) {
 	logger.WithFields(defineFields(ctx, args)).Errorf(errMsg+": %v", err)
 }
 
+// Access is used to log a served request with its latency, response size and member_id when set.
+func Access(ctx *gin.Context, latency time.Duration) {
+	fields := logrus.Fields{
+		"ServiceName": os.Getenv("SERVICE_NAME"),
+		"StatusCode":  ctx.Writer.Status(),
+		"Latency":     latency.Milliseconds(),
+		"Size":        ctx.Writer.Size(),
+	}
+	if requestID, ok := ctx.Get(RequestIDKey); ok {
+		fields["Key"] = requestID
+	}
+	if memberID, ok := ctx.Get("member_id"); ok {
+		fields["MemberID"] = memberID
+	}
+	if req := ctx.Request; req != nil {
+		// the query may carry tokens, only the path is logged
+		fields["Request"] = req.URL.Path
+		fields["Method"] = req.Method
+		fields["IP"] = realIP(req)
+	}
+	accessLogger.WithFields(fields).Info("access")
+}
+
+// SetAccessOutput sets the writer and, when not nil, the formatter of access logs
+func SetAccessOutput(out io.Writer, formatter logrus.Formatter) {
+	accessLogger.SetOutput(out)
+	if formatter != nil {
+		accessLogger.SetFormatter(formatter)
+	}
+}
+
 // Warnf is used to log potentially harmful events.
 func Warnf(errMsg string, err error) {
 	logger.WithFields(logrus.Fields{"Trace": traceStack()}).Warnf(errMsg+": %v", err)
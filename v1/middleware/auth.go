@@ -12,10 +12,10 @@ import (
 
 	"github.com/forkyid/go-utils/v1/aes"
 	"github.com/forkyid/go-utils/v1/cache"
-	"github.com/forkyid/go-utils/v1/jwt"
 	"github.com/forkyid/go-utils/v1/logger"
 	"github.com/forkyid/go-utils/v1/rest"
 	"github.com/forkyid/go-utils/v1/util/age"
+	"github.com/forkyid/go-utils/v1/util/env"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis"
 	"github.com/pkg/errors"
@@ -35,37 +35,68 @@ type MemberStatusKey struct {
 	ID string `cache:"key"`
 }
 
-type banStatus struct {
+type BanStatus struct {
 	IsBanned bool   `json:"is_banned"`
 	TypeName string `json:"type_name,omitempty"`
 }
 
 type MemberStatus struct {
-	banStatus
+	BanStatus
 	DeviceID   string     `json:"device_id,omitempty"`
 	SuspendEnd *time.Time `json:"suspend_end,omitempty"`
 }
 
+// MemberStatusGenerationKey changes on every member status event,
+// a status fetched before the change is not kept in the cache
+type MemberStatusGenerationKey struct {
+	ID string `cache:"key"`
+}
+
+func memberStatusKey(encryptedID string) string {
+	return cache.ExternalKey("global", MemberStatusKey{ID: encryptedID})
+}
+
+func memberStatusGenerationKey(encryptedID string) string {
+	return cache.ExternalKey("global", MemberStatusGenerationKey{ID: encryptedID})
+}
+
+// statusGeneration returns the member status generation, empty when no event was applied recently
+func statusGeneration(encryptedID string) string {
+	var generation string
+	err := cache.GetUnmarshal(memberStatusGenerationKey(encryptedID), &generation)
+	if err != nil && err != redis.Nil {
+		logger.Warnf("redis: get status generation", err)
+	}
+	return generation
+}
+
+// statusTTL keeps the status cached for 10 minutes, or until the suspension ends
+func statusTTL(status MemberStatus) int {
+	if status.SuspendEnd != nil && status.SuspendEnd.After(time.Now().Add(10*time.Minute)) {
+		return int(time.Until(*status.SuspendEnd).Seconds())
+	}
+	return 600
+}
+
+// GetStatus gets the cached member status, the ban status is fetched from the report service on miss
 func GetStatus(ctx *gin.Context, memberID int) (status MemberStatus, err error) {
+	return getStatus(ctx, memberID, httpUpstream{})
+}
+
+func getStatus(ctx *gin.Context, memberID int, bans BanStatusProvider) (status MemberStatus, err error) {
 	isAlive := cache.IsCacheConnected()
 	if !isAlive {
 		logger.Warnf("redis", ErrConnectionFailed)
 	}
 
-	statusKey := cache.ExternalKey("global", MemberStatusKey{
-		ID: aes.Encrypt(memberID),
-	})
+	encryptedID := aes.Encrypt(memberID)
+	statusKey := memberStatusKey(encryptedID)
 
 	// TODO: update suspension checking
 	if isAlive {
 		err = cache.GetUnmarshal(statusKey, &status)
 		if err == nil {
-			if status.SuspendEnd != nil && status.SuspendEnd.After(time.Now().Add(10*time.Minute)) {
-				suspendEnd := time.Until(*status.SuspendEnd)
-				cache.SetExpire(statusKey, int(suspendEnd.Seconds()))
-			} else {
-				cache.SetExpire(statusKey, 600)
-			}
+			cache.SetExpire(statusKey, statusTTL(status))
 			return
 		}
 		if err != redis.Nil {
@@ -73,17 +104,27 @@ func GetStatus(ctx *gin.Context, memberID int) (status MemberStatus, err error)
 		}
 	}
 
-	status.banStatus, err = getBanStatus(ctx)
+	var generation string
+	if isAlive {
+		generation = statusGeneration(encryptedID)
+	}
+	status.BanStatus, err = bans.BanStatus(ctx, ctx.GetHeader("Authorization"))
 	if err != nil {
 		err = errors.Wrap(err, "get ban status")
 		return
 	}
 
 	if isAlive {
-		err = cache.SetJSON(statusKey, status, 600)
+		err = cache.SetJSON(statusKey, status, statusTTL(status))
 		if err != nil {
 			logger.Warnf("redis: set", err)
 		}
+		// an event applied while fetching may have evicted the key before this write
+		if err == nil && statusGeneration(encryptedID) != generation {
+			if err := cache.Delete(statusKey); err != nil {
+				logger.Warnf("redis: delete", err)
+			}
+		}
 	}
 
 	return
@@ -97,19 +138,21 @@ func tokenBasicAuth(username, password string) string {
 func checkAuthToken(bearerToken string) (resp rest.Response, err error) {
 	bearerToken = strings.Replace(bearerToken, "Bearer ", "", -1)
 
-	oauthUsername := os.Getenv("OAUTH2_SERVER_BASIC_AUTH_USERNAME") // TODO: update env naming to `BASIC_AUTH_OAUTH2_SERVER_USERNAME`
-	oauthPassword := os.Getenv("OAUTH2_SERVER_BASIC_AUTH_PASSWORD") // TODO: update env naming to `BASIC_AUTH_OAUTH2_SERVER_PASSWORD`
-	basicAuth := tokenBasicAuth(oauthUsername, oauthPassword)
-
 	payload := map[string]string{"access_token": bearerToken}
 	payloadJson, _ := json.Marshal(payload)
 
 	req := rest.Request{
-		URL:    fmt.Sprintf("%v/oauth/v1/resource/check/token", os.Getenv("API_ORIGIN_URL")), // TODO: update path using internal LB
-		Method: http.MethodPost,
-		Headers: map[string]string{
-			"Authorization": "Basic " + basicAuth},
-		Body: bytes.NewReader(payloadJson),
+		URL:     fmt.Sprintf("%v/oauth/v1/resource/check/token", os.Getenv("API_ORIGIN_URL")), // TODO: update path using internal LB
+		Method:  http.MethodPost,
+		Headers: map[string]string{},
+		Body:    bytes.NewReader(payloadJson),
+		Signer:  rest.DefaultSigner(),
+	}
+	// basic auth is kept next to the signature unless OAUTH2_SERVER_BASIC_AUTH_DISABLED is set
+	if !env.GetBool("OAUTH2_SERVER_BASIC_AUTH_DISABLED", false) {
+		oauthUsername := os.Getenv("OAUTH2_SERVER_BASIC_AUTH_USERNAME") // TODO: update env naming to `BASIC_AUTH_OAUTH2_SERVER_USERNAME`
+		oauthPassword := os.Getenv("OAUTH2_SERVER_BASIC_AUTH_PASSWORD") // TODO: update env naming to `BASIC_AUTH_OAUTH2_SERVER_PASSWORD`
+		req.Headers["Authorization"] = "Basic " + tokenBasicAuth(oauthUsername, oauthPassword)
 	}
 
 	respJson, statusCode := req.Send()
@@ -119,56 +162,14 @@ func checkAuthToken(bearerToken string) (resp rest.Response, err error) {
 	return
 }
 
+// validate authenticates the token and runs the ban, suspension and device checks
 func (mid *Middleware) validate(auth string, ctx *gin.Context) {
-	id, err := jwt.ExtractID(auth)
-	if err != nil {
-		rest.ResponseMessage(ctx, http.StatusUnauthorized).
-			Log("extract id", err)
-		ctx.Abort()
-		return
-	}
-
-	resp, err := checkAuthToken(auth)
-	if err != nil {
-		rest.ResponseMessage(ctx, http.StatusInternalServerError).Log("check auth token", err)
-		ctx.Abort()
-		return
-	}
-
-	if resp.Status != http.StatusOK {
-		rest.ResponseError(ctx, http.StatusUnauthorized, resp.Detail)
-		ctx.Abort()
-		return
-	}
-
-	status, err := GetStatus(ctx, id)
-	if err != nil {
-		rest.ResponseMessage(ctx, http.StatusInternalServerError).Log("get status", err)
-		ctx.Abort()
-		return
-	}
-
-	if status.IsBanned {
-		if status.TypeName == "underage" {
-			rest.ResponseMessage(ctx, http.StatusForbidden, ErrUnderage.Error())
-		} else {
-			rest.ResponseMessage(ctx, http.StatusForbidden, ErrBanned.Error())
+	mid.authenticate(auth, ctx)
+	for _, check := range []gin.HandlerFunc{mid.CheckBan, mid.CheckSuspension, mid.CheckDevice(mid.device)} {
+		if ctx.IsAborted() {
+			return
 		}
-		ctx.Abort()
-		return
-	}
-
-	if status.SuspendEnd != nil && status.SuspendEnd.After(time.Now()) {
-		rest.ResponseMessage(ctx, http.StatusLocked, ErrSuspended.Error())
-		ctx.Abort()
-		return
-	}
-
-	deviceID := ctx.GetHeader("X-Unique-ID")
-	if status.DeviceID != deviceID {
-		rest.ResponseMessage(ctx, http.StatusUnauthorized)
-		ctx.Abort()
-		return
+		check(ctx)
 	}
 }
 
@@ -212,7 +213,7 @@ func (mid *Middleware) AgeAuth(minAge int) gin.HandlerFunc {
 			return
 		}
 
-		claims, _ := jwt.ExtractClient(auth)
+		claims, _ := ClaimsFrom(ctx)
 		if age.Age(claims.DateOfBirth) < minAge {
 			rest.ResponseMessage(ctx, http.StatusForbidden, ErrBelowAgeRequirement.Error())
 			ctx.Abort()
@@ -221,12 +222,13 @@ func (mid *Middleware) AgeAuth(minAge int) gin.HandlerFunc {
 	}
 }
 
-func getBanStatus(ctx *gin.Context) (status banStatus, err error) {
+func getBanStatus(authorization string) (status BanStatus, err error) {
 	req := rest.Request{
 		URL:    fmt.Sprintf("%v/report/v1/bans", os.Getenv("API_ORIGIN_URL")),
 		Method: http.MethodGet,
 		Headers: map[string]string{
-			"Authorization": ctx.GetHeader("Authorization")},
+			"Authorization": authorization},
+		Signer: rest.DefaultSigner(),
 	}
 
 	body, code := req.Send()
@@ -245,12 +247,13 @@ func getBanStatus(ctx *gin.Context) (status banStatus, err error) {
 	return
 }
 
-func getAccStatus(ctx *gin.Context) (isOnHold bool, err error) {
+func getAccStatus(authorization string) (isOnHold bool, err error) {
 	req := rest.Request{
 		URL:    fmt.Sprintf("%v/gs/v1/accounts/status", os.Getenv("API_ORIGIN_URL")),
 		Method: http.MethodGet,
 		Headers: map[string]string{
-			"Authorization": ctx.GetHeader("Authorization")},
+			"Authorization": authorization},
+		Signer: rest.DefaultSigner(),
 	}
 
 	respJson, code := req.Send()
@@ -282,39 +285,8 @@ func getAccStatus(ctx *gin.Context) (isOnHold bool, err error) {
 	return
 }
 
-func (m *Middleware) CheckWaitingStatus(ctx *gin.Context) {
-	if err := m.elastic.WaitForYellowStatus("1s"); err != nil {
-		logger.Errorf(ctx, "wait for yellow status", err)
-		return
-	}
-
-	result, err := m.elastic.Get().
-		Index("waiting-list").
-		Id("status").
-		Do(ctx)
-	if err != nil {
-		logger.Errorf(ctx, "get waiting list status", err)
-		return
-	}
-
-	resultStruct := map[string]bool{}
-
-	if !result.Found {
-		logger.Errorf(ctx, "waiting list status not found", err)
-		return
-	}
-
-	json.Unmarshal(result.Source, &resultStruct)
-	isWait := resultStruct["status"]
-
-	if isWait {
-		rest.ResponseMessage(ctx, http.StatusServiceUnavailable)
-		ctx.Abort()
-	}
-}
-
-func (m Middleware) CheckSimilar(ctx *gin.Context) {
-	isOnHold, err := getAccStatus(ctx)
+func (m *Middleware) CheckSimilar(ctx *gin.Context) {
+	isOnHold, err := m.accountStatusProvider().IsOnHold(ctx, ctx.GetHeader("Authorization"))
 	if err != nil {
 		rest.ResponseMessage(ctx, http.StatusInternalServerError).
 			Log("get account status", err)
@@ -0,0 +1,181 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/forkyid/go-utils/v1/cache/cachetest"
+	"github.com/forkyid/go-utils/v1/featureflag"
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/middleware/middlewaretest"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var cacheServer *cachetest.Server
+
+func TestMain(m *testing.M) {
+	os.Setenv("AES_KEY", "test-salt")
+	os.Setenv("AES_MIN_LENGTH", "8")
+	os.Setenv("JWT_ACCESS_SIGNATURE_KEY", "access-secret")
+	os.Setenv("SERVICE_NAME", "middleware-test")
+	var err error
+	if cacheServer, err = cachetest.Start(); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func issue(t *testing.T, id int, user jwt.UserClaims) string {
+	user.ID = strconv.Itoa(id)
+	token, err := jwt.IssueAccess(user)
+	assert.Nil(t, err)
+	return "Bearer " + token
+}
+
+// serve serves GET / through handlers followed by a 200 handler
+func serve(authorization string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/", append(handlers, func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})...)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAuth(t *testing.T) {
+	cacheServer.Flush()
+	kit := middlewaretest.NewKit()
+	mid := middleware.NewMiddleware(nil, kit.Options()...)
+
+	valid := issue(t, 1, jwt.UserClaims{})
+	banned := issue(t, 2, jwt.UserClaims{})
+	kit.Bans.Set(banned, middleware.BanStatus{IsBanned: true})
+	revoked := issue(t, 3, jwt.UserClaims{})
+	kit.Tokens.Revoke(revoked)
+
+	tests := []struct {
+		name          string
+		authorization string
+		handler       gin.HandlerFunc
+		want          int
+	}{
+		{name: "valid", authorization: valid, handler: mid.Auth, want: http.StatusOK},
+		{name: "no token", handler: mid.Auth, want: http.StatusUnauthorized},
+		{name: "guest without token", handler: mid.GuestAuth, want: http.StatusOK},
+		{name: "malformed", authorization: "Bearer token", handler: mid.GuestAuth, want: http.StatusUnauthorized},
+		{name: "banned", authorization: banned, handler: mid.Auth, want: http.StatusForbidden},
+		{name: "revoked upstream", authorization: revoked, handler: mid.Auth, want: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, serve(test.authorization, test.handler).Code)
+		})
+	}
+}
+
+func TestCheckSimilar(t *testing.T) {
+	kit := middlewaretest.NewKit()
+	mid := middleware.NewMiddleware(nil, kit.Options()...)
+	authorization := issue(t, 1, jwt.UserClaims{})
+
+	assert.Equal(t, http.StatusOK, serve(authorization, mid.CheckSimilar).Code)
+
+	kit.Accounts.Set(authorization, true)
+	assert.Equal(t, http.StatusForbidden, serve(authorization, mid.CheckSimilar).Code)
+
+	kit.Accounts.Err = errors.New("connection refused")
+	assert.Equal(t, http.StatusInternalServerError, serve(authorization, mid.CheckSimilar).Code)
+}
+
+func TestCheckFeatureFlagStatus(t *testing.T) {
+	kit := middlewaretest.NewKit()
+	mid := middleware.NewMiddleware(nil, kit.Options()...)
+
+	assert.Equal(t, http.StatusOK, serve("", mid.CheckFeatureFlagStatus("chat")).Code)
+
+	kit.Flags.Set("chat", middleware.MaintenanceStatus)
+	assert.Equal(t, http.StatusForbidden, serve("", mid.CheckFeatureFlagStatus("chat")).Code)
+}
+
+type flagSource []featureflag.Flag
+
+func (s flagSource) Flags(context.Context) ([]featureflag.Flag, error) {
+	return s, nil
+}
+
+func TestCheckFeatureFlagStatusLocal(t *testing.T) {
+	cacheServer.Flush()
+	client := featureflag.NewClient(flagSource{{
+		Key:   "chat",
+		Rules: []featureflag.Rule{{MemberIDs: []int{1}, Enabled: true}},
+	}}, featureflag.Config{})
+	defer client.Close()
+
+	kit := middlewaretest.NewKit()
+	mid := middleware.NewMiddleware(nil, append(kit.Options(), middleware.WithFlagProvider(middleware.NewLocalFlagProvider(client)))...)
+
+	assert.Equal(t, http.StatusOK, serve(issue(t, 1, jwt.UserClaims{}), mid.Auth, mid.CheckFeatureFlagStatus("chat")).Code)
+	assert.Equal(t, http.StatusForbidden, serve(issue(t, 2, jwt.UserClaims{}), mid.Auth, mid.CheckFeatureFlagStatus("chat")).Code)
+	assert.Equal(t, http.StatusForbidden, serve("", mid.CheckFeatureFlagStatus("chat")).Code)
+}
+
+func TestAuthZeroValue(t *testing.T) {
+	cacheServer.Flush()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/oauth/v1/resource/check/token", r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"token inactive"}`))
+	}))
+	defer upstream.Close()
+	t.Setenv("API_ORIGIN_URL", upstream.URL)
+
+	mid := &middleware.Middleware{}
+	assert.Equal(t, http.StatusUnauthorized, serve(issue(t, 1, jwt.UserClaims{}), mid.Auth).Code)
+}
+
+func TestAuthTokenCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name          string
+		signingSecret string
+		basicDisabled string
+		wantBasic     bool
+	}{
+		{name: "basic auth", wantBasic: true},
+		{name: "signed", signingSecret: "signing-secret", wantBasic: true},
+		{name: "signed without basic auth", signingSecret: "signing-secret", basicDisabled: "true"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cacheServer.Flush()
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _, hasBasic := r.BasicAuth()
+				assert.Equal(t, test.wantBasic, hasBasic)
+				assert.Equal(t, test.signingSecret != "", r.Header.Get(rest.HeaderSignature) != "")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"token inactive"}`))
+			}))
+			defer upstream.Close()
+			t.Setenv("API_ORIGIN_URL", upstream.URL)
+			t.Setenv("REQUEST_SIGNING_SECRET", test.signingSecret)
+			t.Setenv("OAUTH2_SERVER_BASIC_AUTH_DISABLED", test.basicDisabled)
+
+			mid := middleware.NewMiddleware(nil)
+			assert.Equal(t, http.StatusUnauthorized, serve(issue(t, 1, jwt.UserClaims{}), mid.Auth).Code)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInsufficientRole  = errors.New("insufficient role")
+	ErrInsufficientScope = errors.New("insufficient scope")
+	ErrForbidden         = errors.New("forbidden")
+)
+
+// Policy checks resource-level access of the authenticated request, a non-nil error denies it
+type Policy func(ctx *gin.Context, claims *jwt.AccessClaims) error
+
+// require responds 403 with denied unless granted(claims) holds any or all of wanted
+func require(denied error, all bool, wanted []string, granted func(*jwt.AccessClaims) []string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ClaimsFrom(ctx)
+		if !ok {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized)
+			ctx.Abort()
+			return
+		}
+
+		if !matches(granted(claims), wanted, all) {
+			rest.ResponseMessage(ctx, http.StatusForbidden, denied.Error())
+			ctx.Abort()
+			return
+		}
+	}
+}
+
+func matches(granted, wanted []string, all bool) bool {
+	set := make(map[string]bool, len(granted))
+	for _, g := range granted {
+		set[g] = true
+	}
+	for _, w := range wanted {
+		if set[w] && !all {
+			return true
+		}
+		if !set[w] && all {
+			return false
+		}
+	}
+	return all || len(wanted) == 0
+}
+
+// RequireRoles allows members having any of roles, must run after Auth, GuestAuth or AgeAuth
+func (mid *Middleware) RequireRoles(roles ...string) gin.HandlerFunc {
+	return require(ErrInsufficientRole, false, roles, (*jwt.AccessClaims).AllRoles)
+}
+
+// RequireAllRoles allows members having all of roles, must run after Auth, GuestAuth or AgeAuth
+func (mid *Middleware) RequireAllRoles(roles ...string) gin.HandlerFunc {
+	return require(ErrInsufficientRole, true, roles, (*jwt.AccessClaims).AllRoles)
+}
+
+// RequireScopes allows tokens granted all of scopes, must run after Auth, GuestAuth or AgeAuth
+func (mid *Middleware) RequireScopes(scopes ...string) gin.HandlerFunc {
+	return require(ErrInsufficientScope, true, scopes, (*jwt.AccessClaims).Scopes)
+}
+
+// RequireAnyScope allows tokens granted any of scopes, must run after Auth, GuestAuth or AgeAuth
+func (mid *Middleware) RequireAnyScope(scopes ...string) gin.HandlerFunc {
+	return require(ErrInsufficientScope, false, scopes, (*jwt.AccessClaims).Scopes)
+}
+
+// RequirePolicy allows requests accepted by policy, must run after Auth, GuestAuth or AgeAuth
+func (mid *Middleware) RequirePolicy(policy Policy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ClaimsFrom(ctx)
+		if !ok {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized)
+			ctx.Abort()
+			return
+		}
+
+		if err := policy(ctx, claims); err != nil {
+			rest.ResponseMessage(ctx, http.StatusForbidden, ErrForbidden.Error()).
+				Log("policy", err)
+			ctx.Abort()
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	claims := &jwt.AccessClaims{
+		Roles:      []string{"editor"},
+		Scope:      "post:read post:write",
+		UserClaims: jwt.UserClaims{RoleID: "admin"},
+	}
+
+	tests := []struct {
+		name    string
+		claims  *jwt.AccessClaims
+		handler gin.HandlerFunc
+		want    int
+	}{
+		{name: "any role", claims: claims, handler: mid.RequireRoles("editor", "owner"), want: http.StatusOK},
+		{name: "no role", claims: claims, handler: mid.RequireRoles("owner"), want: http.StatusForbidden},
+		{name: "all roles", claims: claims, handler: mid.RequireAllRoles("admin", "editor"), want: http.StatusOK},
+		{name: "missing one role", claims: claims, handler: mid.RequireAllRoles("admin", "owner"), want: http.StatusForbidden},
+		{name: "all scopes", claims: claims, handler: mid.RequireScopes("post:read", "post:write"), want: http.StatusOK},
+		{name: "missing one scope", claims: claims, handler: mid.RequireScopes("post:read", "post:delete"), want: http.StatusForbidden},
+		{name: "any scope", claims: claims, handler: mid.RequireAnyScope("post:delete", "post:read"), want: http.StatusOK},
+		{name: "unauthenticated", handler: mid.RequireRoles("admin"), want: http.StatusUnauthorized},
+		{
+			name:   "policy",
+			claims: claims,
+			handler: mid.RequirePolicy(func(ctx *gin.Context, claims *jwt.AccessClaims) error {
+				if ctx.Param("owner") != claims.Username {
+					return errors.New("not the owner")
+				}
+				return nil
+			}),
+			want: http.StatusForbidden,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/:owner", func(ctx *gin.Context) {
+				if test.claims != nil {
+					ctx.Set(middleware.ClaimsContextKey, test.claims)
+				}
+			}, test.handler, func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/forky", nil))
+			assert.Equal(t, test.want, recorder.Code)
+		})
+	}
+}
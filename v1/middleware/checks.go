@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/logger"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/forkyid/go-utils/v1/util/env"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// reason codes set in the reason detail of rejected requests
+const (
+	ReasonBanned         = "banned"
+	ReasonUnderage       = "underage"
+	ReasonSuspended      = "suspended"
+	ReasonDeviceMismatch = "device_mismatch"
+)
+
+var ErrDeviceMismatch = errors.New("Device Mismatch")
+
+// DeviceCheckConfig configures CheckDevice
+type DeviceCheckConfig struct {
+	// Header holding the device id, X-Unique-ID by default
+	Header string
+	// Skip skips the check for matching requests, e.g. web clients
+	Skip func(ctx *gin.Context) bool
+}
+
+// WithDeviceCheck configures the device check run by Auth, GuestAuth and AgeAuth
+func WithDeviceCheck(config DeviceCheckConfig) Option {
+	return func(m *Middleware) {
+		m.device = config
+	}
+}
+
+// authenticate validates the token, introspects it and stores the claims and member status in ctx
+func (mid *Middleware) authenticate(auth string, ctx *gin.Context) {
+	claims, err := jwt.ExtractClientWithOptions(auth, jwt.Options{})
+	id := -1
+	if err == nil {
+		id, err = claims.MemberID()
+	}
+	if errors.Is(err, jwt.ErrRevocationUnavailable) {
+		rest.ResponseMessage(ctx, http.StatusServiceUnavailable).Log("extract id", err)
+		ctx.Abort()
+		return
+	}
+	if err != nil {
+		msg := []string{}
+		if errors.Is(err, jwt.ErrExpired) {
+			msg = append(msg, jwt.ErrExpired.Error())
+		}
+		rest.ResponseMessage(ctx, http.StatusUnauthorized, msg...).
+			Log("extract id", err)
+		ctx.Abort()
+		return
+	}
+
+	// revoked tokens are rejected by jwt.ExtractClientWithOptions, the remote check can be disabled
+	if env.GetBool("OAUTH2_SERVER_CHECK_TOKEN", true) {
+		resp, err := mid.tokenIntrospector().Introspect(ctx, auth)
+		if err != nil {
+			rest.ResponseMessage(ctx, http.StatusInternalServerError).Log("check auth token", err)
+			ctx.Abort()
+			return
+		}
+
+		if resp.Status != http.StatusOK {
+			rest.ResponseError(ctx, http.StatusUnauthorized, resp.Detail)
+			ctx.Abort()
+			return
+		}
+	}
+
+	status, err := getStatus(ctx, id, mid.banStatusProvider())
+	if err != nil {
+		rest.ResponseMessage(ctx, http.StatusInternalServerError).Log("get status", err)
+		ctx.Abort()
+		return
+	}
+
+	SetAuthContext(ctx, claims, id, status)
+}
+
+// Authenticate requires a valid token without checking the member status,
+// compose it with CheckBan, CheckSuspension and CheckDevice as needed
+func (mid *Middleware) Authenticate(ctx *gin.Context) {
+	auth := ctx.GetHeader("Authorization")
+	if auth == "" {
+		logger.Debugf(ctx, "get header", ErrNoAuthorizationHeader)
+		rest.ResponseMessage(ctx, http.StatusUnauthorized)
+		ctx.Abort()
+		return
+	}
+
+	mid.authenticate(auth, ctx)
+}
+
+// CheckBan rejects banned members with 403, guests are let through
+func (mid *Middleware) CheckBan(ctx *gin.Context) {
+	status, ok := MemberStatusFrom(ctx)
+	if !ok || !status.IsBanned {
+		return
+	}
+
+	if status.TypeName == ReasonUnderage {
+		rest.ResponseError(ctx, http.StatusForbidden, map[string]string{"reason": ReasonUnderage}, ErrUnderage.Error())
+	} else {
+		rest.ResponseError(ctx, http.StatusForbidden, map[string]string{"reason": ReasonBanned}, ErrBanned.Error())
+	}
+	ctx.Abort()
+}
+
+// CheckSuspension rejects suspended members with 423 and the suspension end time, guests are let through
+func (mid *Middleware) CheckSuspension(ctx *gin.Context) {
+	status, ok := MemberStatusFrom(ctx)
+	if !ok || status.SuspendEnd == nil || !status.SuspendEnd.After(time.Now()) {
+		return
+	}
+
+	rest.ResponseError(ctx, http.StatusLocked, map[string]string{
+		"reason":      ReasonSuspended,
+		"suspend_end": status.SuspendEnd.Format(time.RFC3339),
+	}, ErrSuspended.Error())
+	ctx.Abort()
+}
+
+// CheckDevice rejects members whose device header differs from the bound device with 401, guests are let through
+func (mid *Middleware) CheckDevice(config DeviceCheckConfig) gin.HandlerFunc {
+	if config.Header == "" {
+		config.Header = "X-Unique-ID"
+	}
+
+	return func(ctx *gin.Context) {
+		status, ok := MemberStatusFrom(ctx)
+		if !ok || (config.Skip != nil && config.Skip(ctx)) {
+			return
+		}
+
+		if status.DeviceID != ctx.GetHeader(config.Header) {
+			rest.ResponseError(ctx, http.StatusUnauthorized, map[string]string{"reason": ReasonDeviceMismatch}, ErrDeviceMismatch.Error())
+			ctx.Abort()
+		}
+	}
+}
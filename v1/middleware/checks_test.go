@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func withStatus(status *middleware.MemberStatus) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request.Header.Set("X-Unique-ID", "device")
+		if status != nil {
+			ctx.Set(middleware.MemberStatusContextKey, status)
+		}
+	}
+}
+
+func TestStatusChecks(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	suspendEnd := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	web := middleware.DeviceCheckConfig{Skip: func(ctx *gin.Context) bool { return true }}
+
+	tests := []struct {
+		name       string
+		status     *middleware.MemberStatus
+		handler    gin.HandlerFunc
+		wantStatus int
+		wantDetail map[string]string
+	}{
+		{name: "guest", handler: mid.CheckBan, wantStatus: http.StatusOK},
+		{
+			name:       "banned",
+			status:     &middleware.MemberStatus{BanStatus: middleware.BanStatus{IsBanned: true}},
+			handler:    mid.CheckBan,
+			wantStatus: http.StatusForbidden,
+			wantDetail: map[string]string{"reason": middleware.ReasonBanned},
+		},
+		{
+			name:       "underage",
+			status:     &middleware.MemberStatus{BanStatus: middleware.BanStatus{IsBanned: true, TypeName: "underage"}},
+			handler:    mid.CheckBan,
+			wantStatus: http.StatusForbidden,
+			wantDetail: map[string]string{"reason": middleware.ReasonUnderage},
+		},
+		{
+			name:       "suspended",
+			status:     &middleware.MemberStatus{SuspendEnd: &suspendEnd},
+			handler:    mid.CheckSuspension,
+			wantStatus: http.StatusLocked,
+			wantDetail: map[string]string{"reason": middleware.ReasonSuspended, "suspend_end": suspendEnd.Format(time.RFC3339)},
+		},
+		{
+			name:       "device mismatch",
+			status:     &middleware.MemberStatus{DeviceID: "other"},
+			handler:    mid.CheckDevice(middleware.DeviceCheckConfig{}),
+			wantStatus: http.StatusUnauthorized,
+			wantDetail: map[string]string{"reason": middleware.ReasonDeviceMismatch},
+		},
+		{
+			name:       "device check skipped",
+			status:     &middleware.MemberStatus{DeviceID: "other"},
+			handler:    mid.CheckDevice(web),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "device match",
+			status:     &middleware.MemberStatus{DeviceID: "device"},
+			handler:    mid.CheckDevice(middleware.DeviceCheckConfig{}),
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve("", withStatus(test.status), test.handler)
+			assert.Equal(t, test.wantStatus, recorder.Code)
+			if test.wantDetail == nil {
+				return
+			}
+
+			resp := rest.Response{}
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+			assert.Equal(t, test.wantDetail, resp.Detail)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"github.com/forkyid/go-utils/v1/featureflag"
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// gin context keys set by Auth, GuestAuth and AgeAuth once the token is validated,
+// the feature flag target is also set under featureflag.TargetContextKey
+const (
+	ClaimsContextKey       = "claims"
+	MemberIDContextKey     = "member_id"
+	MemberStatusContextKey = "member_status"
+)
+
+// AppVersionHeader app version used to target feature flags
+const AppVersionHeader = "X-App-Version"
+
+// SetAuthContext sets the claims, member id, member status and feature flag target of an authenticated request,
+// as done by Auth once the token is validated
+func SetAuthContext(ctx *gin.Context, claims *jwt.AccessClaims, memberID int, status MemberStatus) {
+	ctx.Set(ClaimsContextKey, claims)
+	ctx.Set(MemberIDContextKey, memberID)
+	ctx.Set(MemberStatusContextKey, &status)
+
+	target := guestTarget(ctx)
+	target.MemberID = memberID
+	target.Roles = claims.AllRoles()
+	ctx.Set(featureflag.TargetContextKey, target)
+}
+
+func guestTarget(ctx *gin.Context) featureflag.Target {
+	return featureflag.Target{
+		AppVersion: ctx.GetHeader(AppVersionHeader),
+		DeviceID:   ctx.GetHeader("X-Unique-ID"),
+	}
+}
+
+// ClaimsFrom returns the access claims of the authenticated request
+func ClaimsFrom(ctx *gin.Context) (*jwt.AccessClaims, bool) {
+	value, _ := ctx.Get(ClaimsContextKey)
+	claims, ok := value.(*jwt.AccessClaims)
+	return claims, ok
+}
+
+// MemberIDFrom returns the member id of the authenticated request
+func MemberIDFrom(ctx *gin.Context) (int, bool) {
+	value, _ := ctx.Get(MemberIDContextKey)
+	id, ok := value.(int)
+	return id, ok
+}
+
+// MemberStatusFrom returns the member status of the authenticated request
+func MemberStatusFrom(ctx *gin.Context) (*MemberStatus, bool) {
+	value, _ := ctx.Get(MemberStatusContextKey)
+	status, ok := value.(*MemberStatus)
+	return status, ok
+}
+
+// KeyIDFrom returns the key id of a request verified by VerifySignature
+func KeyIDFrom(ctx *gin.Context) (string, bool) {
+	value, _ := ctx.Get(KeyIDContextKey)
+	keyID, ok := value.(string)
+	return keyID, ok
+}
@@ -0,0 +1,33 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/middleware/middlewaretest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthContext(t *testing.T) {
+	cacheServer.Flush()
+	mid := middleware.NewMiddleware(nil, middlewaretest.NewKit().Options()...)
+	authorization := issue(t, 42, jwt.UserClaims{Username: "forky"})
+
+	recorder := serve(authorization, mid.Auth, func(ctx *gin.Context) {
+		claims, ok := middleware.ClaimsFrom(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, "forky", claims.Username)
+
+		id, ok := middleware.MemberIDFrom(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, 42, id)
+
+		status, ok := middleware.MemberStatusFrom(ctx)
+		assert.True(t, ok)
+		assert.False(t, status.IsBanned)
+	})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
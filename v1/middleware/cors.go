@@ -1,19 +1,136 @@
 package middleware
 
 import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/rest"
 	"github.com/gin-gonic/gin"
 )
 
-func (*Middleware) CORS(c *gin.Context) {
-	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-	c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-	c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-	c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, x-location-lat, x-location-long, x-unique-id")
-	c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
-	c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-	if c.Request.Method == "OPTIONS" {
-		c.AbortWithStatus(200)
-	} else {
+// CORSConfig configures the CORS middleware
+type CORSConfig struct {
+	// AllowedOrigins exact origins, wildcard subdomains like https://*.forky.id, or * for any origin
+	AllowedOrigins []string
+	// AllowedOriginPatterns origins matching any of the patterns are allowed
+	AllowedOriginPatterns []*regexp.Regexp
+	AllowedMethods        []string
+	AllowedHeaders        []string
+	ExposedHeaders        []string
+	MaxAge                time.Duration
+	// AllowCredentials allows credentials for origins matching an exact, wildcard or pattern entry,
+	// origins allowed only by * never get credentials
+	AllowCredentials bool
+}
+
+// DefaultCORSConfig allows any origin without credentials, with the headers read and written by this package
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{
+			http.MethodPost, http.MethodGet, http.MethodOptions, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		},
+		AllowedHeaders: []string{
+			"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin",
+			"Cache-Control", "X-Requested-With", "X-Location-Lat", "X-Location-Long", "X-Unique-ID", AppVersionHeader,
+			IdempotencyKeyHeader, RequestIDHeader,
+			rest.HeaderKeyID, rest.HeaderTimestamp, rest.HeaderNonce, rest.HeaderSignature,
+		},
+		ExposedHeaders: []string{"Content-Length", RequestIDHeader, IdempotencyReplayedHeader},
+		MaxAge:         24 * time.Hour,
+	}
+}
+
+// WithCORS configures the CORS middleware, DefaultCORSConfig by default
+func WithCORS(config CORSConfig) Option {
+	return func(m *Middleware) {
+		m.cors = config
+	}
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, empty if not allowed.
+// Listed origins are echoed, any other origin gets * when * is allowed.
+func (c CORSConfig) allowOrigin(origin string) string {
+	anyOrigin := false
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			anyOrigin = true
+			continue
+		}
+		if allowed == origin || matchWildcard(allowed, origin) {
+			return origin
+		}
+	}
+	for _, pattern := range c.AllowedOriginPatterns {
+		if pattern.MatchString(origin) {
+			return origin
+		}
+	}
+	if anyOrigin {
+		return "*"
+	}
+	return ""
+}
+
+// matchWildcard matches https://*.forky.id against https://api.forky.id
+func matchWildcard(allowed, origin string) bool {
+	i := strings.Index(allowed, "*")
+	if i < 0 {
+		return false
+	}
+	prefix, suffix := allowed[:i], allowed[i+1:]
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	return !strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:")
+}
+
+// CORS answers preflight requests with 204 and sets the CORS headers of allowed origins
+func (mid *Middleware) CORS(c *gin.Context) {
+	config := mid.cors
+	origin := c.GetHeader("Origin")
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+	header := c.Writer.Header()
+	header.Add("Vary", "Origin")
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	allowOrigin := ""
+	if origin != "" {
+		allowOrigin = config.allowOrigin(origin)
+	}
+	if allowOrigin == "" {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
 		c.Next()
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if config.AllowCredentials && allowOrigin != "*" {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if preflight {
+		header.Set("Access-Control-Allow-Methods", strings.Join(config.AllowedMethods, ", "))
+		header.Set("Access-Control-Allow-Headers", strings.Join(config.AllowedHeaders, ", "))
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+
+	if len(config.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
 	}
+	c.Next()
 }
@@ -0,0 +1,127 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	config := middleware.DefaultCORSConfig()
+	config.AllowedOrigins = []string{"https://forky.id", "https://*.forky.id"}
+	config.AllowedOriginPatterns = []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)}
+	config.AllowCredentials = true
+	config.MaxAge = time.Hour
+	mid := middleware.NewMiddleware(nil, middleware.WithCORS(config))
+
+	router := gin.New()
+	router.Use(mid.CORS)
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{name: "exact", method: http.MethodGet, origin: "https://forky.id", wantStatus: http.StatusOK, wantOrigin: "https://forky.id"},
+		{name: "wildcard", method: http.MethodGet, origin: "https://api.forky.id", wantStatus: http.StatusOK, wantOrigin: "https://api.forky.id"},
+		{name: "regex", method: http.MethodGet, origin: "http://localhost:3000", wantStatus: http.StatusOK, wantOrigin: "http://localhost:3000"},
+		{name: "not allowed", method: http.MethodGet, origin: "https://evil.id", wantStatus: http.StatusOK},
+		{name: "wildcard suffix only", method: http.MethodGet, origin: "https://evilforky.id", wantStatus: http.StatusOK},
+		{name: "preflight", method: http.MethodOptions, origin: "https://forky.id", wantStatus: http.StatusNoContent, wantOrigin: "https://forky.id"},
+		{name: "preflight not allowed", method: http.MethodOptions, origin: "https://evil.id", wantStatus: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(test.method, "/", nil)
+			request.Header.Set("Origin", test.origin)
+			if test.method == http.MethodOptions {
+				request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.wantStatus, recorder.Code)
+			assert.Equal(t, test.wantOrigin, recorder.Header().Get("Access-Control-Allow-Origin"))
+			assert.Contains(t, recorder.Header().Values("Vary"), "Origin")
+			if test.wantOrigin == "" {
+				return
+			}
+			assert.Equal(t, "true", recorder.Header().Get("Access-Control-Allow-Credentials"))
+			if test.method == http.MethodOptions {
+				assert.Equal(t, "3600", recorder.Header().Get("Access-Control-Max-Age"))
+				assert.Contains(t, recorder.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+			}
+		})
+	}
+}
+
+func TestCORSDefault(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	recorder := serve("", func(ctx *gin.Context) {
+		ctx.Request.Header.Set("Origin", "https://forky.id")
+	}, mid.CORS)
+
+	assert.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, recorder.Header().Get("Access-Control-Expose-Headers"), middleware.RequestIDHeader)
+}
+
+func TestCORSDefaultPreflight(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	router := gin.New()
+	router.Use(mid.CORS)
+	router.POST("/", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodOptions, "/", nil)
+	request.Header.Set("Origin", "https://forky.id")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	allowed := recorder.Header().Get("Access-Control-Allow-Headers")
+	for _, header := range []string{middleware.IdempotencyKeyHeader, middleware.RequestIDHeader, rest.HeaderSignature} {
+		assert.Contains(t, allowed, header)
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	config := middleware.DefaultCORSConfig()
+	config.AllowedOrigins = []string{"https://forky.id", "*"}
+	config.AllowCredentials = true
+	mid := middleware.NewMiddleware(nil, middleware.WithCORS(config))
+
+	tests := []struct {
+		name            string
+		origin          string
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{name: "listed", origin: "https://forky.id", wantOrigin: "https://forky.id", wantCredentials: "true"},
+		{name: "unlisted", origin: "https://evil.id", wantOrigin: "*"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serve("", func(ctx *gin.Context) {
+				ctx.Request.Header.Set("Origin", test.origin)
+			}, mid.CORS)
+
+			assert.Equal(t, test.wantOrigin, recorder.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, test.wantCredentials, recorder.Header().Get("Access-Control-Allow-Credentials"))
+		})
+	}
+}
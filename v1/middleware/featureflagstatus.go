@@ -1,11 +1,13 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 
+	"github.com/forkyid/go-utils/v1/featureflag"
 	"github.com/forkyid/go-utils/v1/rest"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
@@ -25,7 +27,11 @@ type FeatureFlagStatus struct {
 // CheckFeatureFlagStatus checks feature flag status by key and abort if status is not enabled.
 func (mid *Middleware) CheckFeatureFlagStatus(key string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		status, err := getFeatureFlagStatus(key)
+		if _, ok := ctx.Get(featureflag.TargetContextKey); !ok {
+			ctx.Set(featureflag.TargetContextKey, guestTarget(ctx))
+		}
+
+		status, err := mid.flagProvider().FlagStatus(ctx, key)
 		if err != nil {
 			rest.ResponseMessage(ctx, http.StatusInternalServerError).Log("get feature flag status", err)
 			ctx.Abort()
@@ -47,11 +53,31 @@ func (mid *Middleware) CheckFeatureFlagStatus(key string) gin.HandlerFunc {
 	}
 }
 
+type localFlagProvider struct {
+	client *featureflag.Client
+}
+
+// NewLocalFlagProvider evaluates flags in memory with client for the member or guest of the request
+func NewLocalFlagProvider(client *featureflag.Client) FlagProvider {
+	return &localFlagProvider{client: client}
+}
+
+func (p *localFlagProvider) FlagStatus(ctx context.Context, key string) (FeatureFlagStatus, error) {
+	if flag, ok := p.client.Flag(key); ok && flag.Status == MaintenanceStatus {
+		return FeatureFlagStatus{Status: MaintenanceStatus}, nil
+	}
+	if !p.client.IsEnabled(ctx, key) {
+		return FeatureFlagStatus{Status: DisabledStatus}, nil
+	}
+	return FeatureFlagStatus{Status: featureflag.StatusEnabled}, nil
+}
+
 // getFeatureFlagStatus gets feature flag status by its key.
 func getFeatureFlagStatus(key string) (status FeatureFlagStatus, err error) {
 	req := rest.Request{
 		URL:    fmt.Sprintf("%v/flag/v1/check?key=%v", os.Getenv("API_ORIGIN_URL"), key),
 		Method: http.MethodGet,
+		Signer: rest.DefaultSigner(),
 	}
 
 	body, code := req.Send()
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const DefaultRetryAfter = time.Second
+
+var (
+	ErrTimeout      = errors.New("request timeout")
+	ErrBodyTooLarge = errors.New("request body too large")
+	ErrOverCapacity = errors.New("server over capacity")
+)
+
+// Timeout sets a deadline of d on the request context and responds 504 when it is exceeded
+// and no response is written. Handlers must pass ctx.Request.Context() (or rest.Request.WithRequestContext)
+// to their upstream calls for them to be cancelled.
+func (mid *Middleware) Timeout(d time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), d)
+		defer cancel()
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		if reqCtx.Err() == context.DeadlineExceeded && !ctx.Writer.Written() {
+			rest.ResponseMessage(ctx, http.StatusGatewayTimeout, ErrTimeout.Error())
+			ctx.Abort()
+		}
+	}
+}
+
+// MaxBodySize responds 413 to requests with a body above limit bytes.
+// Bodies without Content-Length are read up to limit before the handler runs.
+func (mid *Middleware) MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.ContentLength > limit {
+			rest.ResponseMessage(ctx, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+			ctx.Abort()
+			return
+		}
+		if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+			return
+		}
+		if ctx.Request.ContentLength >= 0 {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(ctx.Request.Body, limit+1))
+		if err != nil {
+			rest.ResponseMessage(ctx, http.StatusBadRequest).Log("read body", err)
+			ctx.Abort()
+			return
+		}
+		if int64(len(body)) > limit {
+			rest.ResponseMessage(ctx, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+}
+
+// LoadSheddingConfig configures ShedLoad
+type LoadSheddingConfig struct {
+	// MaxInFlight maximum requests served at once, no limit when <= 0
+	MaxInFlight int
+	// RetryAfter sent as Retry-After when shedding, DefaultRetryAfter by default
+	RetryAfter time.Duration
+}
+
+// ShedLoad responds 503 with Retry-After once MaxInFlight requests are being served by the handler.
+// Each call creates its own limit, use it with router.Use for a service wide limit.
+func (mid *Middleware) ShedLoad(config LoadSheddingConfig) gin.HandlerFunc {
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = DefaultRetryAfter
+	}
+	if config.MaxInFlight <= 0 {
+		return func(ctx *gin.Context) {}
+	}
+	retryAfter := strconv.Itoa(int((config.RetryAfter + time.Second - 1) / time.Second))
+	inFlight := make(chan struct{}, config.MaxInFlight)
+
+	return func(ctx *gin.Context) {
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			ctx.Header("Retry-After", retryAfter)
+			rest.ResponseMessage(ctx, http.StatusServiceUnavailable, ErrOverCapacity.Error())
+			ctx.Abort()
+			return
+		}
+		defer func() { <-inFlight }()
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,122 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		want    int
+	}{
+		{name: "in time", handler: func(ctx *gin.Context) {}, want: http.StatusOK},
+		{name: "exceeded", handler: func(ctx *gin.Context) {
+			<-ctx.Request.Context().Done()
+		}, want: http.StatusGatewayTimeout},
+		{name: "exceeded after response", handler: func(ctx *gin.Context) {
+			ctx.Status(http.StatusAccepted)
+			ctx.Writer.WriteHeaderNow()
+			<-ctx.Request.Context().Done()
+		}, want: http.StatusAccepted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, serve("", mid.Timeout(20*time.Millisecond), test.handler).Code)
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+
+	router := gin.New()
+	router.POST("/", mid.MaxBodySize(16), func(ctx *gin.Context) {
+		body := map[string]interface{}{}
+		if err := rest.BindJSON(ctx, &body); err != nil {
+			rest.ResponseMessage(ctx, http.StatusBadRequest)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+
+	large := `{"text":"` + strings.Repeat("a", 32) + `"}`
+	chunked := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(bytes.NewReader([]byte(large))))
+	chunked.ContentLength = -1
+	chunkedSmall := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(bytes.NewReader([]byte(`{"a":1}`))))
+	chunkedSmall.ContentLength = -1
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    int
+	}{
+		{name: "within limit", request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1}`)), want: http.StatusOK},
+		{name: "content length above limit", request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(large)), want: http.StatusRequestEntityTooLarge},
+		{name: "unknown length within limit", request: chunkedSmall, want: http.StatusOK},
+		{name: "unknown length above limit", request: chunked, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, test.request)
+			assert.Equal(t, test.want, recorder.Code)
+		})
+	}
+}
+
+func TestShedLoad(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	router := gin.New()
+	router.Use(mid.ShedLoad(middleware.LoadSheddingConfig{MaxInFlight: 1, RetryAfter: 1500 * time.Millisecond}))
+	router.GET("/slow", func(ctx *gin.Context) {
+		close(started)
+		<-release
+		ctx.Status(http.StatusOK)
+	})
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- get("/slow").Code
+	}()
+	<-started
+
+	shed := get("/")
+	assert.Equal(t, http.StatusServiceUnavailable, shed.Code)
+	assert.Equal(t, "2", shed.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, get("/").Code)
+}
+
+func TestShedLoadWithoutLimit(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	shed := mid.ShedLoad(middleware.LoadSheddingConfig{})
+	assert.Equal(t, http.StatusOK, serve("", shed).Code)
+}
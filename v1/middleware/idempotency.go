@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/cache"
+	"github.com/forkyid/go-utils/v1/logger"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	DefaultIdempotencyTTL         = 24 * time.Hour
+	DefaultIdempotencyLockTTL     = time.Minute
+	DefaultIdempotencyMaxBodySize = 1 << 20
+)
+
+var (
+	ErrIdempotencyKeyRequired = errors.New("Idempotency-Key header required")
+	ErrIdempotencyInProgress  = errors.New("request with the same Idempotency-Key is in progress")
+	ErrIdempotencyMismatch    = errors.New("Idempotency-Key reused with a different request")
+)
+
+// IdempotencyKey caches the response of a request by member (or device) and Idempotency-Key
+type IdempotencyKey struct {
+	Scope string `cache:"key"`
+	Key   string `cache:"key"`
+}
+
+type idempotentResponse struct {
+	Done        bool   `json:"done"`
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// IdempotencyConfig configures Idempotent
+type IdempotencyConfig struct {
+	// Required responds 400 to requests without Idempotency-Key
+	Required bool
+	// TTL how long responses are replayed, DefaultIdempotencyTTL by default
+	TTL time.Duration
+	// LockTTL how long the key stays locked after the handler stops extending it, e.g. on a crash,
+	// DefaultIdempotencyLockTTL by default. The lock is extended every LockTTL/2 while the handler runs.
+	LockTTL time.Duration
+	// MaxBodySize maximum bytes of the fingerprinted request body, DefaultIdempotencyMaxBodySize by default
+	MaxBodySize int64
+}
+
+// Idempotent replays the stored response to requests retried with the same Idempotency-Key.
+// Keys are scoped to the member set by Auth, or the X-Unique-ID device for guests.
+// Responds 409 while the first request is running or when the key is reused with another method, path or body.
+// The key is released on 5xx responses and panics so the request can be retried. When the response cannot be stored
+// the key stays locked until LockTTL passes. Requests are let through when the cache is down.
+// Keys are prefixed with SERVICE_NAME, it panics if it is not set.
+func (mid *Middleware) Idempotent(config IdempotencyConfig) gin.HandlerFunc {
+	if os.Getenv("SERVICE_NAME") == "" {
+		panic("middleware: Idempotent requires the SERVICE_NAME env")
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultIdempotencyMaxBodySize
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultIdempotencyTTL
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = DefaultIdempotencyLockTTL
+	}
+	// the cache expires keys in whole seconds
+	if config.TTL < time.Second {
+		config.TTL = time.Second
+	}
+	if config.LockTTL < time.Second {
+		config.LockTTL = time.Second
+	}
+
+	return func(ctx *gin.Context) {
+		idempotencyKey := ctx.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			if config.Required {
+				rest.ResponseMessage(ctx, http.StatusBadRequest, ErrIdempotencyKeyRequired.Error())
+				ctx.Abort()
+			}
+			return
+		}
+
+		scope := ctx.GetHeader("X-Unique-ID")
+		if memberID, ok := MemberIDFrom(ctx); ok {
+			scope = strconv.Itoa(memberID)
+		}
+		if scope == "" {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized)
+			ctx.Abort()
+			return
+		}
+
+		var body []byte
+		if ctx.Request.Body != nil {
+			var err error
+			body, err = ioutil.ReadAll(io.LimitReader(ctx.Request.Body, config.MaxBodySize+1))
+			if err != nil {
+				rest.ResponseMessage(ctx, http.StatusBadRequest).Log("read body", err)
+				ctx.Abort()
+				return
+			}
+			if int64(len(body)) > config.MaxBodySize {
+				rest.ResponseMessage(ctx, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+				ctx.Abort()
+				return
+			}
+			ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		fingerprint := requestFingerprint(ctx.Request.Method, ctx.Request.URL.RequestURI(), body)
+
+		key := cache.Key(IdempotencyKey{Scope: scope, Key: idempotencyKey})
+		if key == "" {
+			rest.ResponseMessage(ctx, http.StatusInternalServerError).
+				Log("idempotency key", errors.New("empty cache key, is SERVICE_NAME set"))
+			ctx.Abort()
+			return
+		}
+
+		isLocked, err := cache.SetNX(key, idempotentResponse{Fingerprint: fingerprint}, int(config.LockTTL.Seconds()))
+		if err != nil {
+			logger.Warnf("redis: set nx", err)
+			return
+		}
+		if !isLocked {
+			replayIdempotent(ctx, key, fingerprint)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+
+		unlock := extendLock(key, config.LockTTL)
+		finished := false
+		defer func() {
+			unlock()
+			// release the key on 5xx and panics so the request can be retried
+			if !finished || writer.Status() >= http.StatusInternalServerError {
+				if err := cache.Delete(key); err != nil {
+					logger.Warnf("redis: delete", err)
+				}
+			}
+		}()
+
+		ctx.Next()
+		finished = true
+
+		if writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		// stop extending before storing, an extension would cut the response TTL down to LockTTL
+		unlock()
+		err = cache.SetJSON(key, idempotentResponse{
+			Done:        true,
+			Fingerprint: fingerprint,
+			Status:      writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}, int(config.TTL.Seconds()))
+		if err != nil {
+			logger.Warnf("redis: set", err)
+		}
+	}
+}
+
+// extendLock extends the expiry of key every lockTTL/2 until the returned func is first called
+func extendLock(key string, lockTTL time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockTTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := cache.SetExpire(key, int(lockTTL.Seconds())); err != nil {
+					logger.Warnf("redis: set expire", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+func replayIdempotent(ctx *gin.Context, key, fingerprint string) {
+	stored := idempotentResponse{}
+	err := cache.GetUnmarshal(key, &stored)
+	if err != nil {
+		// the lock expired or was released meanwhile, let the client retry
+		rest.ResponseMessage(ctx, http.StatusConflict, ErrIdempotencyInProgress.Error())
+		ctx.Abort()
+		return
+	}
+
+	switch {
+	case stored.Fingerprint != fingerprint:
+		rest.ResponseMessage(ctx, http.StatusConflict, ErrIdempotencyMismatch.Error())
+	case !stored.Done:
+		rest.ResponseMessage(ctx, http.StatusConflict, ErrIdempotencyInProgress.Error())
+	default:
+		ctx.Header(IdempotencyReplayedHeader, "true")
+		ctx.Data(stored.Status, stored.ContentType, stored.Body)
+	}
+	ctx.Abort()
+}
+
+func requestFingerprint(method, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + "\n" + uri + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
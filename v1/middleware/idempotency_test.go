@@ -0,0 +1,164 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/cache"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotent(t *testing.T) {
+	cacheServer.Flush()
+	mid := middleware.NewMiddleware(nil)
+
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	router := gin.New()
+	router.Use(mid.Idempotent(middleware.IdempotencyConfig{MaxBodySize: 64}))
+	router.POST("/posts", func(ctx *gin.Context) {
+		n := atomic.AddInt32(&calls, 1)
+		ctx.JSON(http.StatusCreated, gin.H{"call": n})
+	})
+	router.POST("/slow", func(ctx *gin.Context) {
+		started <- struct{}{}
+		<-release
+		ctx.Status(http.StatusCreated)
+	})
+	router.POST("/fail", func(ctx *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		ctx.Status(http.StatusInternalServerError)
+	})
+
+	send := func(path, key, device, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		if key != "" {
+			request.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		request.Header.Set("X-Unique-ID", device)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		first := send("/posts", "key-1", "device-1", `{"text":"hi"}`)
+		retry := send("/posts", "key-1", "device-1", `{"text":"hi"}`)
+
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "true", retry.Header().Get(middleware.IdempotencyReplayedHeader))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("scoped to device", func(t *testing.T) {
+		other := send("/posts", "key-1", "device-2", `{"text":"hi"}`)
+		assert.Equal(t, http.StatusCreated, other.Code)
+		assert.Empty(t, other.Header().Get(middleware.IdempotencyReplayedHeader))
+	})
+
+	t.Run("mismatched payload", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, send("/posts", "key-1", "device-1", `{"text":"bye"}`).Code)
+	})
+
+	t.Run("without key", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		send("/posts", "", "device-1", "{}")
+		send("/posts", "", "device-1", "{}")
+		assert.Equal(t, before+2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		done := make(chan int)
+		go func() {
+			done <- send("/slow", "key-2", "device-1", "{}").Code
+		}()
+		<-started
+		assert.Equal(t, http.StatusConflict, send("/slow", "key-2", "device-1", "{}").Code)
+		close(release)
+		assert.Equal(t, http.StatusCreated, <-done)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		body := `{"text":"` + strings.Repeat("a", 64) + `"}`
+		assert.Equal(t, http.StatusRequestEntityTooLarge, send("/posts", "key-4", "device-1", body).Code)
+		assert.Equal(t, before, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("server error not stored", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		assert.Equal(t, http.StatusInternalServerError, send("/fail", "key-3", "device-1", "{}").Code)
+		assert.Equal(t, http.StatusInternalServerError, send("/fail", "key-3", "device-1", "{}").Code)
+		assert.Equal(t, before+2, atomic.LoadInt32(&calls))
+	})
+}
+
+func TestIdempotentLock(t *testing.T) {
+	cacheServer.Flush()
+	mid := middleware.NewMiddleware(nil)
+
+	var calls int32
+	started := make(chan struct{}, 1)
+	router := gin.New()
+	router.Use(mid.Recovery, mid.Idempotent(middleware.IdempotencyConfig{LockTTL: time.Second}))
+	router.POST("/slow", func(ctx *gin.Context) {
+		started <- struct{}{}
+		time.Sleep(1600 * time.Millisecond)
+		ctx.Status(http.StatusCreated)
+	})
+	router.POST("/panic", func(ctx *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		panic("boom")
+	})
+
+	send := func(path, key string) int {
+		request := httptest.NewRequest(http.MethodPost, path, nil)
+		request.Header.Set(middleware.IdempotencyKeyHeader, key)
+		request.Header.Set("X-Unique-ID", "device-1")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	t.Run("extended while running", func(t *testing.T) {
+		done := make(chan int)
+		go func() {
+			done <- send("/slow", "key-slow")
+		}()
+		<-started
+		time.Sleep(1300 * time.Millisecond)
+		assert.Equal(t, http.StatusConflict, send("/slow", "key-slow"))
+		assert.Equal(t, http.StatusCreated, <-done)
+
+		// the stored response keeps its TTL once the lock is no longer extended
+		time.Sleep(600 * time.Millisecond)
+		ttl, err := cache.TTL(cache.Key(middleware.IdempotencyKey{Scope: "device-1", Key: "key-slow"}))
+		assert.Nil(t, err)
+		assert.Greater(t, ttl, float64(60))
+	})
+
+	t.Run("released on panic", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, send("/panic", "key-panic"))
+		assert.Equal(t, http.StatusInternalServerError, send("/panic", "key-panic"))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestIdempotentWithoutServiceName(t *testing.T) {
+	t.Setenv("SERVICE_NAME", "")
+	mid := middleware.NewMiddleware(nil)
+	assert.Panics(t, func() {
+		mid.Idempotent(middleware.IdempotencyConfig{})
+	})
+}
@@ -1,27 +1,96 @@
 package middleware
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/olivere/elastic/v7"
 )
 
 type Middleware struct {
 	elastic *elastic.Client
+
+	waiting *WaitingList
+	// ownsWaiting is set when the waiting list was created by NewMiddleware and is closed by Close
+	ownsWaiting bool
+
+	cors   CORSConfig
+	device DeviceCheckConfig
+
+	tokens   TokenIntrospector
+	bans     BanStatusProvider
+	accounts AccountStatusProvider
+	flags    FlagProvider
+}
+
+// Option configures the middleware
+type Option func(*Middleware)
+
+// WithWaitingList uses w for CheckWaitingStatus instead of the waiting-list/status document
+func WithWaitingList(w *WaitingList) Option {
+	return func(m *Middleware) {
+		m.waiting = w
+	}
 }
 
 func NewMiddleware(
 	elastic *elastic.Client,
+	options ...Option,
 ) *Middleware {
-	return &Middleware{
-		elastic: elastic,
+	m := &Middleware{
+		elastic:  elastic,
+		cors:     DefaultCORSConfig(),
+		tokens:   httpUpstream{},
+		bans:     httpUpstream{},
+		accounts: httpUpstream{},
+		flags:    httpUpstream{},
+	}
+	for _, option := range options {
+		option(m)
+	}
+	if m.waiting == nil && m.elastic != nil {
+		m.waiting = NewWaitingList(NewElasticWaitingListStore(m.elastic), WaitingListConfig{})
+		m.ownsWaiting = true
+	}
+	return m
+}
+
+// Close stops the background refresh of the waiting list created from the elastic client
+func (m *Middleware) Close() {
+	if m.ownsWaiting {
+		m.waiting.Close()
 	}
 }
 
+// Middlewarer is implemented by *Middleware and middlewaretest.Mock
 type Middlewarer interface {
 	Auth(ctx *gin.Context)
 	GuestAuth(ctx *gin.Context)
 	AgeAuth(minAge int) gin.HandlerFunc
+	Authenticate(ctx *gin.Context)
+	CheckBan(ctx *gin.Context)
+	CheckSuspension(ctx *gin.Context)
+	CheckDevice(config DeviceCheckConfig) gin.HandlerFunc
+	CheckSimilar(ctx *gin.Context)
+
+	RequireRoles(roles ...string) gin.HandlerFunc
+	RequireAllRoles(roles ...string) gin.HandlerFunc
+	RequireScopes(scopes ...string) gin.HandlerFunc
+	RequireAnyScope(scopes ...string) gin.HandlerFunc
+	RequirePolicy(policy Policy) gin.HandlerFunc
+
 	CORS(ctx *gin.Context)
-	CheckFeatureFlagStatus(key string)
+	CheckFeatureFlagStatus(key string) gin.HandlerFunc
 	CheckWaitingStatus(ctx *gin.Context)
+	VerifySignature(config SignatureConfig) gin.HandlerFunc
+	Idempotent(config IdempotencyConfig) gin.HandlerFunc
+
+	RequestID(ctx *gin.Context)
+	AccessLog(ctx *gin.Context)
+	Recovery(ctx *gin.Context)
+	Timeout(d time.Duration) gin.HandlerFunc
+	MaxBodySize(limit int64) gin.HandlerFunc
+	ShedLoad(config LoadSheddingConfig) gin.HandlerFunc
 }
+
+var _ Middlewarer = (*Middleware)(nil)
@@ -0,0 +1,142 @@
+// Package middlewaretest provides in-memory upstreams for unit testing the middleware
+// and a Mock middleware for router tests
+package middlewaretest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/rest"
+)
+
+// TokenIntrospector accepts every token except revoked ones
+type TokenIntrospector struct {
+	mu      sync.RWMutex
+	revoked map[string]bool
+	Err     error
+}
+
+// Revoke rejects authorization from now on
+func (f *TokenIntrospector) Revoke(authorization string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.revoked == nil {
+		f.revoked = map[string]bool{}
+	}
+	f.revoked[authorization] = true
+}
+
+func (f *TokenIntrospector) Introspect(_ context.Context, authorization string) (rest.Response, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.Err != nil {
+		return rest.Response{}, f.Err
+	}
+	if f.revoked[authorization] {
+		return rest.Response{Status: http.StatusUnauthorized}, nil
+	}
+	return rest.Response{Status: http.StatusOK}, nil
+}
+
+// BanStatusProvider returns the ban status set per authorization, not banned by default
+type BanStatusProvider struct {
+	mu       sync.RWMutex
+	statuses map[string]middleware.BanStatus
+	Err      error
+}
+
+// Set sets the ban status returned for authorization
+func (f *BanStatusProvider) Set(authorization string, status middleware.BanStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.statuses == nil {
+		f.statuses = map[string]middleware.BanStatus{}
+	}
+	f.statuses[authorization] = status
+}
+
+func (f *BanStatusProvider) BanStatus(_ context.Context, authorization string) (middleware.BanStatus, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.statuses[authorization], f.Err
+}
+
+// AccountStatusProvider returns the on hold status set per authorization, not on hold by default
+type AccountStatusProvider struct {
+	mu     sync.RWMutex
+	onHold map[string]bool
+	Err    error
+}
+
+// Set sets whether the account of authorization is on hold
+func (f *AccountStatusProvider) Set(authorization string, isOnHold bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.onHold == nil {
+		f.onHold = map[string]bool{}
+	}
+	f.onHold[authorization] = isOnHold
+}
+
+func (f *AccountStatusProvider) IsOnHold(_ context.Context, authorization string) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.onHold[authorization], f.Err
+}
+
+// FlagProvider returns the status set per key, enabled by default
+type FlagProvider struct {
+	mu       sync.RWMutex
+	statuses map[string]string
+	Err      error
+}
+
+// Set sets the status of key, e.g. middleware.DisabledStatus
+func (f *FlagProvider) Set(key, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.statuses == nil {
+		f.statuses = map[string]string{}
+	}
+	f.statuses[key] = status
+}
+
+func (f *FlagProvider) FlagStatus(_ context.Context, key string) (middleware.FeatureFlagStatus, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	status, ok := f.statuses[key]
+	if !ok {
+		status = "enabled"
+	}
+	return middleware.FeatureFlagStatus{Status: status}, f.Err
+}
+
+// Kit bundles the fakes of every upstream
+type Kit struct {
+	Tokens   *TokenIntrospector
+	Bans     *BanStatusProvider
+	Accounts *AccountStatusProvider
+	Flags    *FlagProvider
+}
+
+// NewKit creates fakes accepting every request
+func NewKit() *Kit {
+	return &Kit{
+		Tokens:   &TokenIntrospector{},
+		Bans:     &BanStatusProvider{},
+		Accounts: &AccountStatusProvider{},
+		Flags:    &FlagProvider{},
+	}
+}
+
+// Options returns the middleware options injecting the fakes
+func (k *Kit) Options() []middleware.Option {
+	return []middleware.Option{
+		middleware.WithTokenIntrospector(k.Tokens),
+		middleware.WithBanStatusProvider(k.Bans),
+		middleware.WithAccountStatusProvider(k.Accounts),
+		middleware.WithFlagProvider(k.Flags),
+	}
+}
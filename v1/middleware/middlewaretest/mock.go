@@ -0,0 +1,180 @@
+package middlewaretest
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+)
+
+var _ middleware.Middlewarer = (*Mock)(nil)
+
+// guards are the auth, check and authorization middlewares denied by Deny without names
+var guards = map[string]bool{
+	"Auth": true, "GuestAuth": true, "AgeAuth": true, "Authenticate": true,
+	"CheckBan": true, "CheckSuspension": true, "CheckDevice": true, "CheckSimilar": true,
+	"RequireRoles": true, "RequireAllRoles": true, "RequireScopes": true, "RequireAnyScope": true, "RequirePolicy": true,
+	"CheckFeatureFlagStatus": true, "CheckWaitingStatus": true, "VerifySignature": true,
+}
+
+// Mock a middleware.Middlewarer for router tests. Every middleware lets requests through
+// unless denied, the auth middlewares set the member given to WithMember.
+// Recovery recovers panics like middleware.Recovery.
+type Mock struct {
+	mu     sync.RWMutex
+	denied map[string]int
+	status int
+	member *member
+	calls  map[string]int
+}
+
+type member struct {
+	id     int
+	claims *jwt.AccessClaims
+}
+
+// NewMock returns a Mock letting every request through
+func NewMock() *Mock {
+	return &Mock{
+		denied: map[string]int{},
+		calls:  map[string]int{},
+	}
+}
+
+// Deny makes the auth, check and authorization middlewares respond status, or only the named ones,
+// e.g. Deny(403, "Auth", "AgeAuth"). Infrastructure middlewares such as CORS, RequestID or Timeout
+// are only denied by name.
+func (m *Mock) Deny(status int, names ...string) *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(names) == 0 {
+		m.status = status
+		return m
+	}
+	for _, name := range names {
+		m.denied[name] = status
+	}
+	return m
+}
+
+// Allow lets every request through again
+func (m *Mock) Allow() *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = 0
+	m.denied = map[string]int{}
+	return m
+}
+
+// WithMember makes Auth, GuestAuth, AgeAuth and Authenticate set memberID and claims on the context
+func (m *Mock) WithMember(memberID int, claims *jwt.AccessClaims) *Mock {
+	if claims == nil {
+		claims = &jwt.AccessClaims{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.member = &member{id: memberID, claims: claims}
+	return m
+}
+
+// Calls returns how many requests went through the named middleware
+func (m *Mock) Calls(name string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.calls[name]
+}
+
+func (m *Mock) handle(name string, ctx *gin.Context) {
+	m.mu.Lock()
+	m.calls[name]++
+	status, ok := m.denied[name]
+	if !ok && guards[name] {
+		status = m.status
+	}
+	member := m.member
+	m.mu.Unlock()
+
+	if status != 0 {
+		rest.ResponseMessage(ctx, status)
+		ctx.Abort()
+		return
+	}
+
+	switch name {
+	case "Auth", "GuestAuth", "AgeAuth", "Authenticate":
+		if member != nil {
+			middleware.SetAuthContext(ctx, member.claims, member.id, middleware.MemberStatus{})
+		}
+	}
+}
+
+func (m *Mock) handler(name string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		m.handle(name, ctx)
+	}
+}
+
+func (m *Mock) Auth(ctx *gin.Context)            { m.handle("Auth", ctx) }
+func (m *Mock) GuestAuth(ctx *gin.Context)       { m.handle("GuestAuth", ctx) }
+func (m *Mock) AgeAuth(int) gin.HandlerFunc      { return m.handler("AgeAuth") }
+func (m *Mock) Authenticate(ctx *gin.Context)    { m.handle("Authenticate", ctx) }
+func (m *Mock) CheckBan(ctx *gin.Context)        { m.handle("CheckBan", ctx) }
+func (m *Mock) CheckSuspension(ctx *gin.Context) { m.handle("CheckSuspension", ctx) }
+func (m *Mock) CheckSimilar(ctx *gin.Context)    { m.handle("CheckSimilar", ctx) }
+func (m *Mock) CheckDevice(middleware.DeviceCheckConfig) gin.HandlerFunc {
+	return m.handler("CheckDevice")
+}
+
+func (m *Mock) RequireRoles(...string) gin.HandlerFunc    { return m.handler("RequireRoles") }
+func (m *Mock) RequireAllRoles(...string) gin.HandlerFunc { return m.handler("RequireAllRoles") }
+func (m *Mock) RequireScopes(...string) gin.HandlerFunc   { return m.handler("RequireScopes") }
+func (m *Mock) RequireAnyScope(...string) gin.HandlerFunc { return m.handler("RequireAnyScope") }
+func (m *Mock) RequirePolicy(middleware.Policy) gin.HandlerFunc {
+	return m.handler("RequirePolicy")
+}
+
+func (m *Mock) CORS(ctx *gin.Context) { m.handle("CORS", ctx) }
+func (m *Mock) CheckFeatureFlagStatus(string) gin.HandlerFunc {
+	return m.handler("CheckFeatureFlagStatus")
+}
+func (m *Mock) CheckWaitingStatus(ctx *gin.Context) { m.handle("CheckWaitingStatus", ctx) }
+func (m *Mock) VerifySignature(middleware.SignatureConfig) gin.HandlerFunc {
+	return m.handler("VerifySignature")
+}
+func (m *Mock) Idempotent(middleware.IdempotencyConfig) gin.HandlerFunc {
+	return m.handler("Idempotent")
+}
+
+func (m *Mock) RequestID(ctx *gin.Context)            { m.handle("RequestID", ctx) }
+func (m *Mock) AccessLog(ctx *gin.Context)            { m.handle("AccessLog", ctx) }
+func (m *Mock) Timeout(time.Duration) gin.HandlerFunc { return m.handler("Timeout") }
+func (m *Mock) MaxBodySize(int64) gin.HandlerFunc     { return m.handler("MaxBodySize") }
+func (m *Mock) ShedLoad(middleware.LoadSheddingConfig) gin.HandlerFunc {
+	return m.handler("ShedLoad")
+}
+
+func (m *Mock) Recovery(ctx *gin.Context) {
+	m.handle("Recovery", ctx)
+	if ctx.IsAborted() {
+		return
+	}
+
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+		if !ctx.Writer.Written() {
+			rest.ResponseMessage(ctx, http.StatusInternalServerError)
+		}
+		ctx.Abort()
+	}()
+	ctx.Next()
+}
@@ -0,0 +1,96 @@
+package middlewaretest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/middleware/middlewaretest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter(mid middleware.Middlewarer, memberID *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(mid.CORS)
+	router.GET("/", mid.Auth, mid.RequireRoles("admin"), func(ctx *gin.Context) {
+		*memberID, _ = middleware.MemberIDFrom(ctx)
+		ctx.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestMock(t *testing.T) {
+	tests := []struct {
+		name         string
+		mock         *middlewaretest.Mock
+		wantStatus   int
+		wantMemberID int
+		wantRoles    int
+	}{
+		{name: "allow all", mock: middlewaretest.NewMock(), wantStatus: http.StatusOK, wantRoles: 1},
+		{name: "deny all", mock: middlewaretest.NewMock().Deny(http.StatusServiceUnavailable), wantStatus: http.StatusServiceUnavailable},
+		{name: "deny one", mock: middlewaretest.NewMock().Deny(http.StatusForbidden, "RequireRoles"), wantStatus: http.StatusForbidden, wantRoles: 1},
+		{name: "allow again", mock: middlewaretest.NewMock().Deny(http.StatusUnauthorized).Allow(), wantStatus: http.StatusOK, wantRoles: 1},
+		{
+			name:         "inject member",
+			mock:         middlewaretest.NewMock().WithMember(7, &jwt.AccessClaims{Roles: []string{"admin"}}),
+			wantStatus:   http.StatusOK,
+			wantMemberID: 7,
+			wantRoles:    1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var memberID int
+			recorder := httptest.NewRecorder()
+			newRouter(test.mock, &memberID).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, test.wantStatus, recorder.Code)
+			assert.Equal(t, test.wantMemberID, memberID)
+			assert.Equal(t, 1, test.mock.Calls("CORS"))
+			assert.Equal(t, test.wantRoles, test.mock.Calls("RequireRoles"))
+		})
+	}
+}
+
+func TestMockDenyAllKeepsInfrastructure(t *testing.T) {
+	mock := middlewaretest.NewMock().Deny(http.StatusUnauthorized)
+	router := gin.New()
+	router.Use(mock.RequestID, mock.Recovery, mock.Timeout(time.Second))
+	router.GET("/public", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	router.GET("/private", mock.Auth, func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/public", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/private", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, 2, mock.Calls("Timeout"))
+}
+
+func TestMockRecovery(t *testing.T) {
+	mock := middlewaretest.NewMock()
+	router := gin.New()
+	router.Use(mock.Recovery)
+	router.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	recorder := httptest.NewRecorder()
+	assert.NotPanics(t, func() {
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	})
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, 1, mock.Calls("Recovery"))
+}
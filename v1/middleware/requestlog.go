@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/logger"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/forkyid/go-utils/v1/uuid"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RequestIDHeader = "X-Request-Id"
+
+	// RequestIDContextKey gin context key of the request id, read by logger as the log Key
+	RequestIDContextKey = logger.RequestIDKey
+)
+
+// requestIDPattern limits accepted request ids to 128 url safe characters
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID uses the X-Request-Id header as request id, a new uuid when it is missing or invalid.
+// The id is set under RequestIDContextKey and echoed in the response header.
+func (mid *Middleware) RequestID(ctx *gin.Context) {
+	requestID := ctx.GetHeader(RequestIDHeader)
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = uuid.GetUUID()
+	}
+
+	ctx.Set(RequestIDContextKey, requestID)
+	ctx.Header(RequestIDHeader, requestID)
+	ctx.Next()
+}
+
+// RequestIDFrom returns the request id set by RequestID
+func RequestIDFrom(ctx *gin.Context) (string, bool) {
+	value, _ := ctx.Get(RequestIDContextKey)
+	requestID, ok := value.(string)
+	return requestID, ok && requestID != ""
+}
+
+// AccessLog logs every request once served, with status, latency, response size and member id
+func (mid *Middleware) AccessLog(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+	logger.Access(ctx, time.Since(start))
+}
+
+// Recovery recovers panics of the following handlers, logs them with the stack trace
+// and responds 500 unless a response is already written.
+// http.ErrAbortHandler is panicked again for net/http to abort the response.
+func (mid *Middleware) Recovery(ctx *gin.Context) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+
+		err := fmt.Errorf("%v\n%s", recovered, debug.Stack())
+		if ctx.Writer.Written() {
+			logger.Errorf(ctx, "panic recovered", err)
+			ctx.Abort()
+			return
+		}
+		rest.ResponseMessage(ctx, http.StatusInternalServerError).Log("panic recovered", err)
+		ctx.Abort()
+	}()
+	ctx.Next()
+}
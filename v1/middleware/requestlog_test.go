@@ -0,0 +1,137 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/logger"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+
+	tests := []struct {
+		name      string
+		requestID string
+		generated bool
+	}{
+		{name: "forwarded", requestID: "req-1"},
+		{name: "missing", generated: true},
+		{name: "invalid", requestID: "bad id\n", generated: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fromContext string
+			router := gin.New()
+			router.GET("/", mid.RequestID, func(ctx *gin.Context) {
+				fromContext, _ = middleware.RequestIDFrom(ctx)
+				ctx.Status(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.requestID != "" {
+				request.Header.Set(middleware.RequestIDHeader, test.requestID)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			echoed := recorder.Header().Get(middleware.RequestIDHeader)
+			assert.Equal(t, fromContext, echoed)
+			if test.generated {
+				assert.NotEmpty(t, echoed)
+				assert.NotEqual(t, test.requestID, echoed)
+			} else {
+				assert.Equal(t, test.requestID, echoed)
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+
+	router := gin.New()
+	router.Use(mid.RequestID, mid.AccessLog, mid.Recovery)
+	router.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/ok", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	request.Header.Set(middleware.RequestIDHeader, "req-panic")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	response := rest.Response{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "req-panic", response.Error)
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), response.Message)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestAccessLog(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger.SetAccessOutput(output, &logrus.JSONFormatter{})
+	defer logger.SetAccessOutput(os.Stderr, &logrus.TextFormatter{FullTimestamp: true, ForceColors: true, DisableQuote: true})
+
+	mid := middleware.NewMiddleware(nil)
+	router := gin.New()
+	router.Use(mid.RequestID, mid.AccessLog)
+	router.GET("/posts", func(ctx *gin.Context) {
+		ctx.Set(middleware.MemberIDContextKey, 42)
+		time.Sleep(10 * time.Millisecond)
+		ctx.String(http.StatusCreated, "hello")
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/posts?access_token=secret", nil)
+	request.Header.Set(middleware.RequestIDHeader, "req-access")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	entry := struct {
+		Latency    int64
+		StatusCode int
+		Size       int
+		MemberID   int
+		Key        string
+		Request    string
+		Method     string
+	}{}
+	assert.Nil(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.GreaterOrEqual(t, entry.Latency, int64(10))
+	assert.Equal(t, http.StatusCreated, entry.StatusCode)
+	assert.Equal(t, len("hello"), entry.Size)
+	assert.Equal(t, 42, entry.MemberID)
+	assert.Equal(t, "req-access", entry.Key)
+	assert.Equal(t, "/posts", entry.Request)
+	assert.Equal(t, http.MethodGet, entry.Method)
+	assert.NotContains(t, output.String(), "secret")
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	router := gin.New()
+	router.Use(mid.Recovery)
+	router.GET("/abort", func(ctx *gin.Context) {
+		panic(http.ErrAbortHandler)
+	})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	})
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/cache"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultSignatureMaxSkew     = 5 * time.Minute
+	DefaultSignatureMaxBodySize = 1 << 20
+
+	// KeyIDContextKey gin context key of the key id of a verified signed request
+	KeyIDContextKey = "key_id"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing request signature")
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrExpiredSignature = errors.New("expired request signature")
+	ErrReplayedRequest  = errors.New("replayed request")
+)
+
+// RequestNonceKey caches used nonces of signed requests
+type RequestNonceKey struct {
+	KeyID string `cache:"key"`
+	Nonce string `cache:"key"`
+}
+
+// SignatureConfig configures VerifySignature
+type SignatureConfig struct {
+	// Secret returns the secret of keyID, ok is false for unknown keys
+	Secret func(keyID string) (secret []byte, ok bool)
+	// MaxSkew maximum difference between the timestamp and now, DefaultSignatureMaxSkew by default
+	MaxSkew time.Duration
+	// MaxBodySize maximum bytes of the signed body, DefaultSignatureMaxBodySize by default
+	MaxBodySize int64
+}
+
+// StaticSecrets returns a SignatureConfig.Secret looking up secrets by key id.
+// It panics if a key id maps to an empty secret.
+func StaticSecrets(secrets map[string]string) func(keyID string) ([]byte, bool) {
+	for keyID, secret := range secrets {
+		if secret == "" {
+			panic("middleware: StaticSecrets requires a secret for key " + keyID)
+		}
+	}
+	return func(keyID string) ([]byte, bool) {
+		secret, ok := secrets[keyID]
+		return []byte(secret), ok
+	}
+}
+
+// VerifySignature requires requests signed by rest.HMACSigner with a known key,
+// a timestamp within MaxSkew and a nonce not used before. The key id is set under KeyIDContextKey.
+// It panics if config.Secret is nil.
+func (mid *Middleware) VerifySignature(config SignatureConfig) gin.HandlerFunc {
+	if config.Secret == nil {
+		panic("middleware: VerifySignature requires SignatureConfig.Secret")
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultSignatureMaxSkew
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultSignatureMaxBodySize
+	}
+
+	return func(ctx *gin.Context) {
+		keyID := ctx.GetHeader(rest.HeaderKeyID)
+		timestamp := ctx.GetHeader(rest.HeaderTimestamp)
+		nonce := ctx.GetHeader(rest.HeaderNonce)
+		signature := ctx.GetHeader(rest.HeaderSignature)
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized, ErrMissingSignature.Error())
+			ctx.Abort()
+			return
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || math.Abs(time.Since(time.Unix(unix, 0)).Seconds()) > config.MaxSkew.Seconds() {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized, ErrExpiredSignature.Error())
+			ctx.Abort()
+			return
+		}
+
+		secret, ok := config.Secret(keyID)
+		if !ok {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized, ErrInvalidSignature.Error())
+			ctx.Abort()
+			return
+		}
+
+		var body []byte
+		if ctx.Request.Body != nil {
+			body, err = ioutil.ReadAll(io.LimitReader(ctx.Request.Body, config.MaxBodySize+1))
+			if err != nil {
+				rest.ResponseMessage(ctx, http.StatusBadRequest).Log("read body", err)
+				ctx.Abort()
+				return
+			}
+			if int64(len(body)) > config.MaxBodySize {
+				rest.ResponseMessage(ctx, http.StatusRequestEntityTooLarge, ErrBodyTooLarge.Error())
+				ctx.Abort()
+				return
+			}
+			ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		expected := rest.Signature(secret, ctx.Request.Method, ctx.Request.URL.RequestURI(), body, timestamp, nonce)
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized, ErrInvalidSignature.Error())
+			ctx.Abort()
+			return
+		}
+
+		// nonces are kept for twice the skew, older timestamps are rejected above
+		nonceKey := cache.ExternalKey("global", RequestNonceKey{KeyID: keyID, Nonce: nonce})
+		isNew, err := cache.SetNX(nonceKey, unix, int(2*config.MaxSkew.Seconds()))
+		if err != nil {
+			rest.ResponseMessage(ctx, http.StatusInternalServerError).Log("set request nonce", err)
+			ctx.Abort()
+			return
+		}
+		if !isNew {
+			rest.ResponseMessage(ctx, http.StatusUnauthorized, ErrReplayedRequest.Error())
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set(KeyIDContextKey, keyID)
+	}
+}
@@ -0,0 +1,94 @@
+package middleware_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	secret := []byte("service-secret")
+
+	router := gin.New()
+	router.POST("/events", mid.VerifySignature(middleware.SignatureConfig{
+		Secret:      middleware.StaticSecrets(map[string]string{"report": string(secret)}),
+		MaxBodySize: 16,
+	}), func(ctx *gin.Context) {
+		keyID, _ := middleware.KeyIDFrom(ctx)
+		body, _ := ioutil.ReadAll(ctx.Request.Body)
+		ctx.String(http.StatusOK, keyID+":"+string(body))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	t.Run("signed request", func(t *testing.T) {
+		req := rest.Request{
+			URL:    server.URL + "/events?id=1",
+			Method: http.MethodPost,
+			Body:   bytes.NewReader([]byte(`{"id":1}`)),
+			Signer: &rest.HMACSigner{KeyID: "report", Secret: secret},
+		}
+		body, code := req.Send()
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, `report:{"id":1}`, string(body))
+	})
+
+	sign := func(keyID string, secret []byte, timestamp time.Time, nonce, body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader([]byte(body)))
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		request.Header.Set(rest.HeaderKeyID, keyID)
+		request.Header.Set(rest.HeaderTimestamp, ts)
+		request.Header.Set(rest.HeaderNonce, nonce)
+		request.Header.Set(rest.HeaderSignature, rest.Signature(secret, http.MethodPost, "/events", []byte(body), ts, nonce))
+		return request
+	}
+
+	tampered := sign("report", secret, time.Now(), "nonce-tampered", "{}")
+	tampered.Body = ioutil.NopCloser(bytes.NewReader([]byte(`{"id":2}`)))
+	unsigned := httptest.NewRequest(http.MethodPost, "/events", nil)
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    int
+	}{
+		{name: "valid", request: sign("report", secret, time.Now(), "nonce-1", "{}"), want: http.StatusOK},
+		{name: "replayed", request: sign("report", secret, time.Now(), "nonce-1", "{}"), want: http.StatusUnauthorized},
+		{name: "missing headers", request: unsigned, want: http.StatusUnauthorized},
+		{name: "unknown key", request: sign("chat", secret, time.Now(), "nonce-2", "{}"), want: http.StatusUnauthorized},
+		{name: "wrong secret", request: sign("report", []byte("other"), time.Now(), "nonce-3", "{}"), want: http.StatusUnauthorized},
+		{name: "expired", request: sign("report", secret, time.Now().Add(-time.Hour), "nonce-4", "{}"), want: http.StatusUnauthorized},
+		{name: "tampered body", request: tampered, want: http.StatusUnauthorized},
+		{name: "body too large", request: sign("report", secret, time.Now(), "nonce-5", `{"id":"0123456789"}`), want: http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, test.request)
+			assert.Equal(t, test.want, recorder.Code)
+		})
+	}
+}
+
+func TestVerifySignatureWithoutSecret(t *testing.T) {
+	mid := middleware.NewMiddleware(nil)
+	assert.Panics(t, func() {
+		mid.VerifySignature(middleware.SignatureConfig{})
+	})
+}
+
+func TestStaticSecretsEmpty(t *testing.T) {
+	assert.Panics(t, func() {
+		middleware.StaticSecrets(map[string]string{"report": ""})
+	})
+}
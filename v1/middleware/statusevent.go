@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/forkyid/go-utils/v1/cache"
+	nsqconsumer "github.com/forkyid/go-utils/v1/nsq/consumer/v1"
+	nsqpublisher "github.com/forkyid/go-utils/v1/nsq/publisher/v1"
+	rabbitmqconsumer "github.com/forkyid/go-utils/v1/rabbitmq/consumer/v1"
+	rabbitmqpublisher "github.com/forkyid/go-utils/v1/rabbitmq/publisher/v1"
+	"github.com/forkyid/go-utils/v1/util/env"
+	"github.com/forkyid/go-utils/v1/uuid"
+	"github.com/nsqio/go-nsq"
+	"github.com/pkg/errors"
+)
+
+// member status event types
+const (
+	MemberStatusEventBan          = "ban"
+	MemberStatusEventUnban        = "unban"
+	MemberStatusEventSuspend      = "suspend"
+	MemberStatusEventUnsuspend    = "unsuspend"
+	MemberStatusEventDeviceChange = "device_change"
+
+	DefaultMemberStatusTopic = "member-status"
+
+	// memberStatusGenerationTTL outlives any status fetch running during an event
+	memberStatusGenerationTTL = 600
+)
+
+var ErrInvalidMemberStatusEvent = errors.New("invalid member status event")
+
+// MemberStatusEvent a moderation change of a member, its cached MemberStatus is evicted
+// and fetched again from the source
+type MemberStatusEvent struct {
+	Type string `json:"type"`
+	// MemberID aes encrypted member id
+	MemberID string `json:"member_id"`
+}
+
+func (e MemberStatusEvent) validate() error {
+	if e.MemberID == "" {
+		return errors.Wrap(ErrInvalidMemberStatusEvent, "missing member_id")
+	}
+	switch e.Type {
+	case MemberStatusEventBan, MemberStatusEventUnban, MemberStatusEventSuspend,
+		MemberStatusEventUnsuspend, MemberStatusEventDeviceChange:
+		return nil
+	}
+	return errors.Wrap(ErrInvalidMemberStatusEvent, fmt.Sprintf("unknown type %v", e.Type))
+}
+
+// ApplyMemberStatusEvent evicts the cached MemberStatus of the event member,
+// it is fetched again from the source on the next request
+func ApplyMemberStatusEvent(event MemberStatusEvent) error {
+	if err := event.validate(); err != nil {
+		return err
+	}
+	// the generation changes first so a status fetched before the event is not cached after the delete
+	err := cache.SetJSON(memberStatusGenerationKey(event.MemberID), uuid.GetUUID(), memberStatusGenerationTTL)
+	if err != nil {
+		return errors.Wrap(err, "set member status generation")
+	}
+	return errors.Wrap(cache.Delete(memberStatusKey(event.MemberID)), "delete member status")
+}
+
+// HandleMemberStatusEvent applies a JSON encoded MemberStatusEvent, usable as a broker handler
+func HandleMemberStatusEvent(body []byte) error {
+	event := MemberStatusEvent{}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return errors.Wrap(ErrInvalidMemberStatusEvent, err.Error())
+	}
+	return ApplyMemberStatusEvent(event)
+}
+
+// memberStatusTopic returns MEMBER_STATUS_TOPIC, DefaultMemberStatusTopic by default
+func memberStatusTopic() string {
+	return env.GetStr("MEMBER_STATUS_TOPIC", DefaultMemberStatusTopic)
+}
+
+// SubscribeMemberStatusNSQ applies the member status events of the NSQ topic on channel, unique per service
+func SubscribeMemberStatusNSQ(channel string) (*nsq.Consumer, error) {
+	return nsqconsumer.Subscribe(memberStatusTopic(), channel, HandleMemberStatusEvent)
+}
+
+// SubscribeMemberStatusRabbitMQ applies the member status events of the route queue, unique per service
+func SubscribeMemberStatusRabbitMQ(route *rabbitmqpublisher.Route) error {
+	return rabbitmqconsumer.Consume(route, HandleMemberStatusEvent)
+}
+
+// PublishMemberStatusNSQ publishes event to the member status NSQ topic
+func PublishMemberStatusNSQ(event MemberStatusEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+	return nsqpublisher.PublishTo(memberStatusTopic(), body)
+}
+
+// PublishMemberStatusRabbitMQ publishes event through route
+func PublishMemberStatusRabbitMQ(route *rabbitmqpublisher.Route, event MemberStatusEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "marshal")
+	}
+	return route.Publish(&rabbitmqpublisher.Publish{Body: string(body)})
+}
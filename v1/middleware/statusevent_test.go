@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/aes"
+	"github.com/forkyid/go-utils/v1/cache"
+	"github.com/forkyid/go-utils/v1/jwt"
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/forkyid/go-utils/v1/middleware/middlewaretest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemberStatusEvent(t *testing.T) {
+	cacheServer.Flush()
+	kit := middlewaretest.NewKit()
+	mid := middleware.NewMiddleware(nil, kit.Options()...)
+	authorization := issue(t, 100, jwt.UserClaims{})
+	memberID := aes.Encrypt(100)
+
+	// the ban status is cached on the first request
+	kit.Bans.Set(authorization, middleware.BanStatus{IsBanned: true})
+	assert.Equal(t, http.StatusForbidden, serve(authorization, mid.Auth).Code)
+	kit.Bans.Set(authorization, middleware.BanStatus{})
+	assert.Equal(t, http.StatusForbidden, serve(authorization, mid.Auth).Code)
+
+	body, err := json.Marshal(middleware.MemberStatusEvent{Type: middleware.MemberStatusEventUnban, MemberID: memberID})
+	assert.Nil(t, err)
+	assert.Nil(t, middleware.HandleMemberStatusEvent(body))
+	assert.Equal(t, http.StatusOK, serve(authorization, mid.Auth).Code)
+
+	// statuses written by the source are evicted as well
+	suspendEnd := time.Now().Add(time.Hour)
+	key := cache.ExternalKey("global", middleware.MemberStatusKey{ID: memberID})
+	assert.Nil(t, cache.SetJSON(key, middleware.MemberStatus{SuspendEnd: &suspendEnd}, 600))
+	assert.Equal(t, http.StatusLocked, serve(authorization, mid.Auth).Code)
+
+	assert.Nil(t, middleware.ApplyMemberStatusEvent(middleware.MemberStatusEvent{
+		Type:     middleware.MemberStatusEventUnsuspend,
+		MemberID: memberID,
+	}))
+	exists, err := cache.IsCacheExists(key)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, http.StatusOK, serve(authorization, mid.Auth).Code)
+}
+
+// racingBans applies an event while the ban status is fetched, returning the status from before it
+type racingBans struct {
+	event middleware.MemberStatusEvent
+}
+
+func (b racingBans) BanStatus(context.Context, string) (middleware.BanStatus, error) {
+	if err := middleware.ApplyMemberStatusEvent(b.event); err != nil {
+		return middleware.BanStatus{}, err
+	}
+	return middleware.BanStatus{IsBanned: true}, nil
+}
+
+func TestMemberStatusEventDuringFetch(t *testing.T) {
+	cacheServer.Flush()
+	t.Setenv("OAUTH2_SERVER_CHECK_TOKEN", "false")
+	memberID := aes.Encrypt(101)
+	mid := middleware.NewMiddleware(nil, middleware.WithBanStatusProvider(racingBans{
+		event: middleware.MemberStatusEvent{Type: middleware.MemberStatusEventUnban, MemberID: memberID},
+	}))
+
+	assert.Equal(t, http.StatusForbidden, serve(issue(t, 101, jwt.UserClaims{}), mid.Auth).Code)
+
+	// the status fetched before the unban is not kept
+	exists, err := cache.IsCacheExists(cache.ExternalKey("global", middleware.MemberStatusKey{ID: memberID}))
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestMemberStatusEventInvalid(t *testing.T) {
+	assert.ErrorIs(t, middleware.HandleMemberStatusEvent([]byte(`{"type":"ban"}`)), middleware.ErrInvalidMemberStatusEvent)
+	assert.ErrorIs(t, middleware.HandleMemberStatusEvent([]byte(`{"type":"mute","member_id":"x"}`)), middleware.ErrInvalidMemberStatusEvent)
+	assert.ErrorIs(t, middleware.HandleMemberStatusEvent([]byte(`not json`)), middleware.ErrInvalidMemberStatusEvent)
+}
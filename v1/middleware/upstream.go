@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/forkyid/go-utils/v1/rest"
+)
+
+// TokenIntrospector checks an access token with the authorization server
+type TokenIntrospector interface {
+	// Introspect returns the server response, a Status other than 200 rejects the token
+	Introspect(ctx context.Context, authorization string) (rest.Response, error)
+}
+
+// BanStatusProvider gets the ban status of the member owning the token
+type BanStatusProvider interface {
+	BanStatus(ctx context.Context, authorization string) (BanStatus, error)
+}
+
+// AccountStatusProvider gets whether the account owning the token is on hold
+type AccountStatusProvider interface {
+	IsOnHold(ctx context.Context, authorization string) (bool, error)
+}
+
+// FlagProvider gets the status of a feature flag
+type FlagProvider interface {
+	FlagStatus(ctx context.Context, key string) (FeatureFlagStatus, error)
+}
+
+// httpUpstream calls the oauth, report, gs and flag services at API_ORIGIN_URL
+type httpUpstream struct{}
+
+func (httpUpstream) Introspect(_ context.Context, authorization string) (rest.Response, error) {
+	return checkAuthToken(authorization)
+}
+
+func (httpUpstream) BanStatus(_ context.Context, authorization string) (BanStatus, error) {
+	return getBanStatus(authorization)
+}
+
+func (httpUpstream) IsOnHold(_ context.Context, authorization string) (bool, error) {
+	return getAccStatus(authorization)
+}
+
+func (httpUpstream) FlagStatus(_ context.Context, key string) (FeatureFlagStatus, error) {
+	return getFeatureFlagStatus(key)
+}
+
+// tokenIntrospector returns the configured TokenIntrospector, httpUpstream when unset
+// so a zero value Middleware works
+func (m *Middleware) tokenIntrospector() TokenIntrospector {
+	if m.tokens == nil {
+		return httpUpstream{}
+	}
+	return m.tokens
+}
+
+// banStatusProvider returns the configured BanStatusProvider, httpUpstream when unset
+func (m *Middleware) banStatusProvider() BanStatusProvider {
+	if m.bans == nil {
+		return httpUpstream{}
+	}
+	return m.bans
+}
+
+// accountStatusProvider returns the configured AccountStatusProvider, httpUpstream when unset
+func (m *Middleware) accountStatusProvider() AccountStatusProvider {
+	if m.accounts == nil {
+		return httpUpstream{}
+	}
+	return m.accounts
+}
+
+// flagProvider returns the configured FlagProvider, httpUpstream when unset
+func (m *Middleware) flagProvider() FlagProvider {
+	if m.flags == nil {
+		return httpUpstream{}
+	}
+	return m.flags
+}
+
+// WithTokenIntrospector replaces the oauth token check of Auth, GuestAuth and AgeAuth
+func WithTokenIntrospector(tokens TokenIntrospector) Option {
+	return func(m *Middleware) {
+		m.tokens = tokens
+	}
+}
+
+// WithBanStatusProvider replaces the report service ban status lookup on member status cache miss
+func WithBanStatusProvider(bans BanStatusProvider) Option {
+	return func(m *Middleware) {
+		m.bans = bans
+	}
+}
+
+// WithAccountStatusProvider replaces the gs service account status lookup of CheckSimilar
+func WithAccountStatusProvider(accounts AccountStatusProvider) Option {
+	return func(m *Middleware) {
+		m.accounts = accounts
+	}
+}
+
+// WithFlagProvider replaces the flag service lookup of CheckFeatureFlagStatus
+func WithFlagProvider(flags FlagProvider) Option {
+	return func(m *Middleware) {
+		m.flags = flags
+	}
+}
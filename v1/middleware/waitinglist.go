@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/cache"
+	"github.com/forkyid/go-utils/v1/logger"
+	"github.com/forkyid/go-utils/v1/rest"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis"
+	"github.com/olivere/elastic/v7"
+	"github.com/pkg/errors"
+)
+
+const (
+	WaitingListIndex      = "waiting-list"
+	WaitingListDocumentID = "status"
+
+	DefaultWaitingListRefreshInterval = 10 * time.Second
+
+	// defaultWaitingListStaleRefreshes refresh intervals after which a snapshot is stale by default
+	defaultWaitingListStaleRefreshes = 6
+)
+
+// WaitingListStore reads and writes the waiting list status
+type WaitingListStore interface {
+	Status(ctx context.Context) (isWait bool, err error)
+	SetStatus(ctx context.Context, isWait bool) error
+}
+
+type waitingListDocument struct {
+	Status bool `json:"status"`
+}
+
+type elasticWaitingListStore struct {
+	client *elastic.Client
+}
+
+// NewElasticWaitingListStore stores the status in the waiting-list/status document.
+// A missing document means the waiting list is off.
+func NewElasticWaitingListStore(client *elastic.Client) WaitingListStore {
+	return &elasticWaitingListStore{client: client}
+}
+
+func (s *elasticWaitingListStore) Status(ctx context.Context) (isWait bool, err error) {
+	result, err := s.client.Get().
+		Index(WaitingListIndex).
+		Id(WaitingListDocumentID).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "get waiting list status")
+	}
+	if !result.Found {
+		return false, nil
+	}
+
+	doc := waitingListDocument{}
+	err = json.Unmarshal(result.Source, &doc)
+	return doc.Status, errors.Wrap(err, "unmarshal")
+}
+
+func (s *elasticWaitingListStore) SetStatus(ctx context.Context, isWait bool) error {
+	_, err := s.client.Index().
+		Index(WaitingListIndex).
+		Id(WaitingListDocumentID).
+		BodyJson(waitingListDocument{Status: isWait}).
+		Refresh("true").
+		Do(ctx)
+	return errors.Wrap(err, "set waiting list status")
+}
+
+type cacheWaitingListStore struct {
+	key string
+}
+
+// NewCacheWaitingListStore stores the status in redis through the cache package.
+// A missing key means the waiting list is off.
+func NewCacheWaitingListStore() WaitingListStore {
+	return &cacheWaitingListStore{
+		key: cache.ExternalKey("global", nil, WaitingListIndex, WaitingListDocumentID),
+	}
+}
+
+func (s *cacheWaitingListStore) Status(ctx context.Context) (isWait bool, err error) {
+	doc := waitingListDocument{}
+	err = cache.GetUnmarshal(s.key, &doc)
+	if err == redis.Nil {
+		return false, nil
+	}
+	return doc.Status, err
+}
+
+func (s *cacheWaitingListStore) SetStatus(ctx context.Context, isWait bool) error {
+	return cache.SetJSON(s.key, waitingListDocument{Status: isWait}, 0)
+}
+
+// WaitingListConfig waiting list snapshot configuration
+type WaitingListConfig struct {
+	RefreshInterval time.Duration // default DefaultWaitingListRefreshInterval
+	// MaxStaleness how long the last loaded status is trusted while refreshes fail,
+	// 6 refresh intervals by default
+	MaxStaleness time.Duration
+	// FailClosed rejects requests while the status has never been loaded or is older than MaxStaleness,
+	// by default requests are let through
+	FailClosed bool
+}
+
+// WaitingList keeps an in-memory snapshot of the store, refreshed in the background.
+// When a refresh fails the last known status is kept, up to MaxStaleness.
+type WaitingList struct {
+	store  WaitingListStore
+	config WaitingListConfig
+
+	mu       sync.RWMutex
+	isWait   bool
+	loadedAt time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWaitingList loads the status and starts refreshing it, call Close to stop
+func NewWaitingList(store WaitingListStore, config WaitingListConfig) *WaitingList {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = DefaultWaitingListRefreshInterval
+	}
+	if config.MaxStaleness <= 0 {
+		config.MaxStaleness = defaultWaitingListStaleRefreshes * config.RefreshInterval
+	}
+
+	w := &WaitingList{
+		store:  store,
+		config: config,
+		stop:   make(chan struct{}),
+	}
+	w.refresh()
+	go w.run()
+	return w
+}
+
+func (w *WaitingList) run() {
+	ticker := time.NewTicker(w.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.refresh()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *WaitingList) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.RefreshInterval)
+	defer cancel()
+
+	isWait, err := w.store.Status(ctx)
+	if err != nil {
+		logger.Warnf("refresh waiting list status", err)
+		return
+	}
+
+	w.mu.Lock()
+	w.isWait, w.loadedAt = isWait, time.Now()
+	w.mu.Unlock()
+}
+
+// IsWaiting returns the snapshot, loaded is false while the status has never been loaded
+// or was last loaded more than MaxStaleness ago
+func (w *WaitingList) IsWaiting() (isWait, loaded bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.isWait, !w.loadedAt.IsZero() && time.Since(w.loadedAt) <= w.config.MaxStaleness
+}
+
+// Set writes the status to the store and updates the snapshot right away
+func (w *WaitingList) Set(ctx context.Context, isWait bool) error {
+	if err := w.store.SetStatus(ctx, isWait); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.isWait, w.loadedAt = isWait, time.Now()
+	w.mu.Unlock()
+	return nil
+}
+
+// Close stops the background refresh
+func (w *WaitingList) Close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// CheckWaitingStatus aborts with 503 while the waiting list is on
+func (m *Middleware) CheckWaitingStatus(ctx *gin.Context) {
+	waiting := m.waiting
+	if waiting == nil {
+		logger.Errorf(ctx, "check waiting status", errors.New("no waiting list store configured"))
+		ctx.Next()
+		return
+	}
+
+	isWait, loaded := waiting.IsWaiting()
+	if !loaded && waiting.config.FailClosed {
+		rest.ResponseMessage(ctx, http.StatusServiceUnavailable)
+		ctx.Abort()
+		return
+	}
+
+	if isWait {
+		rest.ResponseMessage(ctx, http.StatusServiceUnavailable)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Next()
+}
@@ -0,0 +1,93 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type waitingListStore struct {
+	mu     sync.Mutex
+	isWait bool
+	err    error
+}
+
+func (s *waitingListStore) Status(context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isWait, s.err
+}
+
+func (s *waitingListStore) SetStatus(_ context.Context, isWait bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isWait = isWait
+	return s.err
+}
+
+func (s *waitingListStore) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func serveWaitingList(waiting *middleware.WaitingList) int {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	mid := middleware.NewMiddleware(nil, middleware.WithWaitingList(waiting))
+	router.GET("/", mid.CheckWaitingStatus, func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	return recorder.Code
+}
+
+func TestCheckWaitingStatus(t *testing.T) {
+	store := &waitingListStore{}
+	waiting := middleware.NewWaitingList(store, middleware.WaitingListConfig{})
+	defer waiting.Close()
+	assert.Equal(t, http.StatusOK, serveWaitingList(waiting))
+
+	assert.Nil(t, waiting.Set(context.Background(), true))
+	assert.True(t, store.isWait)
+	assert.Equal(t, http.StatusServiceUnavailable, serveWaitingList(waiting))
+}
+
+func TestCheckWaitingStatusUnavailableStore(t *testing.T) {
+	store := &waitingListStore{err: errors.New("connection refused")}
+
+	failOpen := middleware.NewWaitingList(store, middleware.WaitingListConfig{})
+	defer failOpen.Close()
+	assert.Equal(t, http.StatusOK, serveWaitingList(failOpen))
+
+	failClosed := middleware.NewWaitingList(store, middleware.WaitingListConfig{FailClosed: true})
+	defer failClosed.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, serveWaitingList(failClosed))
+}
+
+func TestCheckWaitingStatusStale(t *testing.T) {
+	store := &waitingListStore{}
+	config := middleware.WaitingListConfig{RefreshInterval: 10 * time.Millisecond, MaxStaleness: 50 * time.Millisecond}
+
+	failOpen := middleware.NewWaitingList(store, config)
+	defer failOpen.Close()
+	config.FailClosed = true
+	failClosed := middleware.NewWaitingList(store, config)
+	defer failClosed.Close()
+	assert.Equal(t, http.StatusOK, serveWaitingList(failClosed))
+
+	store.fail(errors.New("connection refused"))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, serveWaitingList(failOpen))
+	assert.Equal(t, http.StatusServiceUnavailable, serveWaitingList(failClosed))
+}
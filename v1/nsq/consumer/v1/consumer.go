@@ -0,0 +1,45 @@
+package v1
+
+import (
+	"log"
+	"strings"
+
+	"github.com/nsqio/go-nsq"
+
+	"github.com/forkyid/go-utils/v1/util/env"
+)
+
+type nopLogger struct{}
+
+func (*nopLogger) Output(int, string) error {
+	return nil
+}
+
+// Subscribe consumes topic on channel with handler, a handler error requeues the message.
+// Connects to NSQLOOKUPD_HOST (comma separated) when set, to NSQD_HOST otherwise. Call Stop on the consumer to stop.
+func Subscribe(topic, channel string, handler func(body []byte) error) (*nsq.Consumer, error) {
+	config := nsq.NewConfig()
+	config.Set("heartbeat_interval", "10s")
+
+	consumer, err := nsq.NewConsumer(topic, channel, config)
+	if err != nil {
+		log.Println("failed to create nsq consumer: ", err.Error())
+		return nil, err
+	}
+	consumer.SetLogger(&nopLogger{}, 0)
+	consumer.AddHandler(nsq.HandlerFunc(func(message *nsq.Message) error {
+		return handler(message.Body)
+	}))
+
+	if lookupd := env.GetStr("NSQLOOKUPD_HOST"); lookupd != "" {
+		err = consumer.ConnectToNSQLookupds(strings.Split(lookupd, ","))
+	} else {
+		err = consumer.ConnectToNSQD(env.GetStr("NSQD_HOST"))
+	}
+	if err != nil {
+		log.Println("failed to connect nsq consumer: ", err.Error())
+		consumer.Stop()
+		return nil, err
+	}
+	return consumer, nil
+}
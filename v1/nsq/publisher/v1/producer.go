@@ -21,8 +21,12 @@ const (
 )
 
 func Publish(data []byte) (err error) {
+	return PublishTo(env.GetStr("NSQD_TOPIC"), data)
+}
+
+// PublishTo publishes data to topic instead of NSQD_TOPIC
+func PublishTo(topic string, data []byte) (err error) {
 	publishType := env.GetStr("NSQD_PUB_TYPE", PubTypeNSQD)
-	topic := env.GetStr("NSQD_TOPIC")
 
 	switch publishType {
 	case PubTypeHTTP:
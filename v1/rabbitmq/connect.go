@@ -12,9 +12,14 @@ import (
 var conn *amqp.Connection
 var channel *amqp.Channel
 
+// mu guards conn and channel, shared by the publisher and the consumer
+var mu sync.Mutex
+
+// Start returns the shared channel, dialing the connection and opening the channel when needed.
+// m is kept for compatibility, every caller is serialized by the package lock.
 func Start(m *sync.Mutex) (*amqp.Channel, error) {
-	m.Lock()
-	defer m.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
 
 	var err error
 	if conn == nil {
@@ -36,17 +41,14 @@ func Start(m *sync.Mutex) (*amqp.Channel, error) {
 			return nil, err
 		}
 
-		go func() {
-			errChan := conn.NotifyClose(make(chan *amqp.Error))
-			for {
-				if <-errChan != nil {
-					err = <-errChan
-					logger.Errorf(nil, "amqp: connection notify close", err)
-					conn = nil
-					return
-				}
+		go func(closed chan *amqp.Error) {
+			if err := <-closed; err != nil {
+				logger.Errorf(nil, "amqp: connection notify close", err)
 			}
-		}()
+			mu.Lock()
+			conn, channel = nil, nil
+			mu.Unlock()
+		}(conn.NotifyClose(make(chan *amqp.Error, 1)))
 
 		logger.Infof("Successfully dialed connection to RabbitMQ.")
 	}
@@ -60,18 +62,16 @@ func Start(m *sync.Mutex) (*amqp.Channel, error) {
 			return nil, err
 		}
 
-		go func() {
-			errChan := channel.NotifyClose(make(chan *amqp.Error))
-			for {
-				if <-errChan != nil {
-					err = <-errChan
-					logger.Errorf(nil, "amqp: channel notify close", err)
-					conn = nil
-					channel = nil
-					return
-				}
+		go func(current *amqp.Channel, closed chan *amqp.Error) {
+			if err := <-closed; err != nil {
+				logger.Errorf(nil, "amqp: channel notify close", err)
+			}
+			mu.Lock()
+			if channel == current {
+				channel = nil
 			}
-		}()
+			mu.Unlock()
+		}(channel, channel.NotifyClose(make(chan *amqp.Error, 1)))
 
 		logger.Infof("Successfully opened channel to RabbitMQ.")
 	}
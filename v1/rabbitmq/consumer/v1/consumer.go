@@ -0,0 +1,185 @@
+package v1
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/rabbitmq"
+	publisher "github.com/forkyid/go-utils/v1/rabbitmq/publisher/v1"
+	"github.com/forkyid/go-utils/v1/util/env"
+	"github.com/streadway/amqp"
+)
+
+const (
+	DefaultMaxAttempts = 5
+
+	// reconnect delays after the channel closes, doubled up to maxReconnectDelay
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+
+	// attemptsHeader counts the deliveries of a requeued message
+	attemptsHeader = "x-attempts"
+)
+
+var m sync.Mutex
+
+// Consume declares and binds the queue of route and consumes it with handler in the background.
+// Messages are acked when handler succeeds. Failed messages are published again to the end of the queue
+// up to RABBITMQ_MAX_ATTEMPTS (DefaultMaxAttempts) times, then rejected so the queue can dead-letter them.
+// When the connection or channel closes the queue is consumed again once RabbitMQ is reachable.
+func Consume(route *publisher.Route, handler func(body []byte) error) error {
+	channel, deliveries, err := subscribe(route)
+	if err != nil {
+		return err
+	}
+
+	maxAttempts := env.GetInt("RABBITMQ_MAX_ATTEMPTS", DefaultMaxAttempts)
+	go func() {
+		for {
+			closed := channel.NotifyClose(make(chan *amqp.Error, 1))
+			for delivery := range deliveries {
+				if err := handler(delivery.Body); err != nil {
+					log.Println(fmt.Sprintf("%s: %s", "Failed to handle a message", err.Error()))
+					retry(channel, route, delivery, maxAttempts)
+					continue
+				}
+				delivery.Ack(false)
+			}
+			select {
+			case err := <-closed:
+				log.Println(fmt.Sprintf("%s: %v", "RabbitMQ channel closed", err))
+			default:
+				log.Println("RabbitMQ consumer cancelled")
+			}
+			channel, deliveries = resubscribe(route)
+		}
+	}()
+	return nil
+}
+
+// resubscribe retries subscribe with an increasing delay until it succeeds
+func resubscribe(route *publisher.Route) (*amqp.Channel, <-chan amqp.Delivery) {
+	delay := minReconnectDelay
+	for {
+		time.Sleep(delay)
+		channel, deliveries, err := subscribe(route)
+		if err == nil {
+			log.Println(fmt.Sprintf("%s: %s", "Consuming RabbitMQ queue again", route.QueueName))
+			return channel, deliveries
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// subscribe declares and binds the queue of route and starts consuming it
+func subscribe(route *publisher.Route) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	channel, err := rabbitmq.Start(&m)
+	if err != nil {
+		log.Println(fmt.Sprintf("%s: %s", "Failed to Connect to RabbitMQ", err.Error()))
+		return nil, nil, err
+	}
+
+	err = channel.ExchangeDeclare(
+		route.ExchangeName, // name
+		route.ExchangeType, // type
+		true,               // durable
+		false,              // auto-delete
+		false,              // internal
+		false,              // no-wait
+		nil,                // argument
+	)
+	if err != nil {
+		log.Println(fmt.Sprintf("%s: %s", "Failed to declare an exchange", err.Error()))
+		return nil, nil, err
+	}
+
+	args := amqp.Table{
+		"x-queue-mode":   "lazy",
+		"x-max-priority": 255,
+	}
+	_, err = channel.QueueDeclare(
+		route.QueueName, // queue name
+		true,            // durable
+		false,           // delete when used
+		false,           // exclusive
+		false,           // no-wait
+		args,            // arguments
+	)
+	if err != nil {
+		log.Println(fmt.Sprintf("%s: %s", "Failed to declare a queue", err.Error()))
+		return nil, nil, err
+	}
+
+	err = channel.QueueBind(
+		route.QueueName,    // queue name
+		route.RoutingKey,   // routing key
+		route.ExchangeName, // exchange
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		log.Println(fmt.Sprintf("%s: %s", "Failed to bind a queue", err.Error()))
+		return nil, nil, err
+	}
+
+	deliveries, err := channel.Consume(
+		route.QueueName, // queue name
+		"",              // consumer
+		false,           // auto-ack
+		false,           // exclusive
+		false,           // no-local
+		false,           // no-wait
+		nil,             // arguments
+	)
+	if err != nil {
+		log.Println(fmt.Sprintf("%s: %s", "Failed to consume a queue", err.Error()))
+		return nil, nil, err
+	}
+
+	return channel, deliveries, nil
+}
+
+// retry publishes delivery again with its attempts counted, it is rejected once maxAttempts is reached
+// or when it cannot be published again, requeued by the broker in that case
+func retry(channel *amqp.Channel, route *publisher.Route, delivery amqp.Delivery, maxAttempts int) {
+	attempts := 1
+	if count, ok := delivery.Headers[attemptsHeader].(int32); ok {
+		attempts = int(count) + 1
+	}
+	if attempts >= maxAttempts {
+		log.Println(fmt.Sprintf("%s: %d attempts", "Rejecting a message", attempts))
+		delivery.Nack(false, false)
+		return
+	}
+
+	headers := amqp.Table{}
+	for k, v := range delivery.Headers {
+		headers[k] = v
+	}
+	headers[attemptsHeader] = int32(attempts)
+
+	// the default exchange routes to the queue only, not to the other queues bound to the route exchange
+	err := channel.Publish(
+		"",              // exchange
+		route.QueueName, // routing key
+		false,           // mandatory
+		false,           // immediate
+		amqp.Publishing{
+			Headers:      headers,
+			ContentType:  delivery.ContentType,
+			DeliveryMode: delivery.DeliveryMode,
+			Priority:     delivery.Priority,
+			Body:         delivery.Body,
+		},
+	)
+	if err != nil {
+		log.Println(fmt.Sprintf("%s: %s", "Failed to requeue a message", err.Error()))
+		delivery.Nack(false, true)
+		return
+	}
+	delivery.Ack(false)
+}
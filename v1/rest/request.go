@@ -1,6 +1,8 @@
 package rest
 
 import (
+	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"log"
@@ -16,6 +18,10 @@ type Request struct {
 	Headers map[string]string
 	Body    io.Reader
 	Queries map[string]string
+	// Signer signs the request before it is sent, e.g. DefaultSigner()
+	Signer Signer
+	// Context cancels the request when done, set with WithRequestContext
+	Context context.Context
 }
 
 // ValidMethod params
@@ -47,7 +53,23 @@ func (request *Request) Send() ([]byte, int) {
 		return nil, -1
 	}
 
-	req, _ := http.NewRequest(request.Method, request.URL, request.Body)
+	requestBody := request.Body
+	var bodyBytes []byte
+	if request.Signer != nil && requestBody != nil {
+		var err error
+		bodyBytes, err = ioutil.ReadAll(requestBody)
+		if err != nil {
+			log.Println("ERROR: ["+request.Method+"] read body", err.Error())
+			return nil, -1
+		}
+		requestBody = bytes.NewReader(bodyBytes)
+	}
+
+	reqCtx := request.Context
+	if reqCtx == nil {
+		reqCtx = context.Background()
+	}
+	req, _ := http.NewRequestWithContext(reqCtx, request.Method, request.URL, requestBody)
 
 	for k, v := range request.Headers {
 		req.Header.Set(k, v)
@@ -61,6 +83,13 @@ func (request *Request) Send() ([]byte, int) {
 		req.URL.RawQuery = q.Encode()
 	}
 
+	if request.Signer != nil {
+		if err := request.Signer.Sign(req, bodyBytes); err != nil {
+			log.Println("ERROR: ["+request.Method+"] sign", err.Error())
+			return nil, -1
+		}
+	}
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -74,6 +103,14 @@ func (request *Request) Send() ([]byte, int) {
 	return body, resp.StatusCode
 }
 
+// WithRequestContext cancels the request when ctx is done, e.g. ctx.Request.Context()
+// to apply the deadline of the middleware Timeout. The incoming request context is also
+// cancelled once the handler returns, do not use it for requests outliving the handler.
+func (request *Request) WithRequestContext(ctx context.Context) *Request {
+	request.Context = ctx
+	return request
+}
+
 // WithContext params
 // @ctx: *gin.Context
 // return *Request
@@ -35,6 +35,7 @@ type ResponsePaginationResult struct {
 	TotalData int         `json:"total_data"`
 	Page      int         `json:"page"`
 	TotalPage int         `json:"total_page"`
+	Next      string      `json:"next,omitempty"` // cursor of the next page for cursor based pagination
 }
 
 // ResponsePaginationParams types
@@ -42,6 +43,7 @@ type ResponsePaginationParams struct {
 	Data       interface{}
 	TotalData  int
 	Pagination *pagination.Pagination
+	Next       string // optional next page cursor, e.g. from elasticsearch.CursorSearch
 }
 
 // ResponseResult types
@@ -52,7 +54,7 @@ type ResponseResult struct {
 
 // Log uses current response context to log
 func (resp ResponseResult) Log(errMsg string, err error, args ...interface{}) {
-	resp.Context.Set("response_id", resp.UUID)
+	resp.Context.Set(logger.RequestIDKey, resp.UUID)
 	if args == nil || (len(args) > 0 && args[0] == nil) { // send nil interface if no value
 		logger.Errorf(resp.Context, errMsg, err, nil)
 		return
@@ -95,7 +97,7 @@ func ResponseData(context *gin.Context, status int, payload interface{}, msg ...
 	var copied gin.Context = *context
 	PublishLog(&copied, status, payload, msg[0])
 	context.JSON(status, response)
-	return ResponseResult{context, uuid.GetUUID()}
+	return ResponseResult{context, errorID(context)}
 }
 
 // ResponsePagination params
@@ -122,6 +124,7 @@ func ResponsePagination(context *gin.Context, status int, params ResponsePaginat
 			TotalData: params.TotalData,
 			Page:      params.Pagination.Page,
 			TotalPage: params.TotalData / params.Pagination.Limit,
+			Next:      params.Next,
 		},
 		Message: msg,
 	}
@@ -129,7 +132,7 @@ func ResponsePagination(context *gin.Context, status int, params ResponsePaginat
 	var copied gin.Context = *context
 	PublishLog(&copied, status, params.Data, msg)
 	context.JSON(status, response)
-	return ResponseResult{context, uuid.GetUUID()}
+	return ResponseResult{context, errorID(context)}
 }
 
 // ResponseMessage params
@@ -151,7 +154,7 @@ func ResponseMessage(context *gin.Context, status int, msg ...string) ResponseRe
 		Message: msg[0],
 	}
 	if status < 200 || status > 299 {
-		response.Error = uuid.GetUUID()
+		response.Error = errorID(context)
 	}
 
 	var copied gin.Context = *context
@@ -160,6 +163,14 @@ func ResponseMessage(context *gin.Context, status int, msg ...string) ResponseRe
 	return ResponseResult{context, response.Error}
 }
 
+// errorID returns the request id set in context, a new uuid if none is set
+func errorID(context *gin.Context) string {
+	if id := context.GetString(logger.RequestIDKey); id != "" {
+		return id
+	}
+	return uuid.GetUUID()
+}
+
 // ResponseError params
 // @context: *gin.Context
 // status: int
@@ -175,7 +186,7 @@ func ResponseError(context *gin.Context, status int, detail interface{}, msg ...
 	}
 
 	response := Response{
-		Error:   uuid.GetUUID(),
+		Error:   errorID(context),
 		Message: msg[0],
 	}
 
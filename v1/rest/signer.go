@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/forkyid/go-utils/v1/util/env"
+	"github.com/forkyid/go-utils/v1/uuid"
+)
+
+// request signature headers set by HMACSigner
+const (
+	HeaderKeyID     = "X-Key-ID"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// Signer signs outgoing requests, body is the raw request body
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// HMACSigner signs the method, path with query, body hash, timestamp and nonce with HMAC-SHA256
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+}
+
+// DefaultSigner returns an HMACSigner for REQUEST_SIGNING_KEY_ID (SERVICE_NAME by default)
+// and REQUEST_SIGNING_SECRET, nil if the secret is not set
+func DefaultSigner() Signer {
+	secret := env.GetStr("REQUEST_SIGNING_SECRET")
+	if secret == "" {
+		return nil
+	}
+	return &HMACSigner{
+		KeyID:  env.GetStr("REQUEST_SIGNING_KEY_ID", env.GetStr("SERVICE_NAME")),
+		Secret: []byte(secret),
+	}
+}
+
+// Sign sets the signature headers of req
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.GetUUID()
+
+	req.Header.Set(HeaderKeyID, s.KeyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Signature(s.Secret, req.Method, req.URL.RequestURI(), body, timestamp, nonce))
+	return nil
+}
+
+// Signature returns the base64 HMAC-SHA256 of the newline joined method, uri, hex sha256 of body, timestamp and nonce
+func Signature(secret []byte, method, uri string, body []byte, timestamp, nonce string) string {
+	bodyHash := sha256.Sum256(body)
+	canonical := strings.Join([]string{method, uri, hex.EncodeToString(bodyHash[:]), timestamp, nonce}, "\n")
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}